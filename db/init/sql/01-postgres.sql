@@ -8,4 +8,6 @@ CREATE USER twitch_bot PASSWORD 'twitch_bot';
 GRANT apps TO twitch_bot;
 
 CREATE USER api PASSWORD 'api';
-GRANT apps TO api;
\ No newline at end of file
+GRANT apps TO api;
+CREATE USER trackernet PASSWORD 'trackernet';
+GRANT apps TO trackernet;
@@ -0,0 +1,2 @@
+alter table users_twitch add column command_prefix varchar(3) default '!' not null;
+alter table users_twitch add column mention_only boolean default false not null;
@@ -0,0 +1 @@
+alter table users_twitch add column language varchar(5) default 'en' not null;
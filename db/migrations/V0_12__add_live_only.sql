@@ -0,0 +1 @@
+alter table users_twitch add column live_only bool default false not null;
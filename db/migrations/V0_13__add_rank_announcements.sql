@@ -0,0 +1,3 @@
+alter table users_twitch add column rank_announce bool default false not null;
+alter table users_twitch add column rank_announce_interval int default 300 not null;
+alter table users_twitch add column rank_announce_format varchar(500) default '' not null;
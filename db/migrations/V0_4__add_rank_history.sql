@@ -0,0 +1,13 @@
+create table if not exists rank_history (
+    id bigint not null generated always as identity,
+    platform varchar(20) not null,
+    player varchar(255) not null,
+    playlist varchar(30) not null,
+    mmr integer not null,
+    rank integer not null,
+    division integer not null,
+    recorded_at timestamptz not null default now(),
+    primary key (id)
+);
+
+create index if not exists rank_history_player_idx on rank_history (platform, player, playlist, recorded_at desc);
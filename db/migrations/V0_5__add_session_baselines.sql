@@ -0,0 +1,8 @@
+create table if not exists twitch_session_baselines (
+    twitch_user_id varchar(20) not null,
+    playlist varchar(30) not null,
+    mmr integer not null,
+    recorded_at timestamptz not null default now(),
+    primary key (twitch_user_id, playlist),
+    foreign key (twitch_user_id) references users_twitch (twitch_user_id) on delete cascade
+);
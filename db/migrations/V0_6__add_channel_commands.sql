@@ -0,0 +1,11 @@
+create table if not exists channel_commands (
+    twitch_user_id varchar(20) not null,
+    command_name varchar(50) not null,
+    aliases text[] not null default '{}',
+    rl_platform varchar(20),
+    rl_username varchar(255),
+    rl_message_format varchar(500),
+    cooldown integer not null default 10,
+    primary key (twitch_user_id, command_name),
+    foreign key (twitch_user_id) references users_twitch (twitch_user_id) on delete cascade
+);
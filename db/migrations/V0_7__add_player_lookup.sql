@@ -0,0 +1,2 @@
+alter table users_twitch add column lookup_enabled boolean default false not null;
+alter table users_twitch add column lookup_cooldown integer default 30 not null;
@@ -0,0 +1,3 @@
+alter table users_twitch add column user_cooldown integer default 0 not null;
+alter table users_twitch add column cooldown_exempt boolean default false not null;
+alter table users_twitch add column cooldown_whisper boolean default false not null;
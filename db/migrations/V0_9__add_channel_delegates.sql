@@ -0,0 +1,9 @@
+-- delegates are identified by their user id, a login given up after a rename could otherwise be taken over.
+-- delegate_login is only kept for display.
+create table if not exists channel_delegates (
+    twitch_user_id varchar(20) not null,
+    delegate_user_id varchar(20) not null,
+    delegate_login varchar(30) not null,
+    primary key (twitch_user_id, delegate_user_id),
+    foreign key (twitch_user_id) references users_twitch (twitch_user_id) on delete cascade
+);
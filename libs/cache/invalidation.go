@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"sync"
+)
+
+const invalidationChannel = "cache:invalidation"
+
+type InvalidationType string
+
+const (
+	// TwitchChannelChanged is sent after a row of users_twitch or its channel commands changed.
+	TwitchChannelChanged InvalidationType = "twitch_channel_changed"
+)
+
+type InvalidationEvent struct {
+	Type         InvalidationType `json:"type"`
+	TwitchUserId string           `json:"twitchUserId,omitempty"`
+	Origin       string           `json:"origin"`
+}
+
+// InvalidationBus tells all services sharing the redis instance that data changed.
+// The publisher clears the shared redis cache itself, subscribers drop the copies they keep in memory.
+// Events are also delivered to the instance that published them.
+type InvalidationBus struct {
+	cache    *Cache
+	origin   string
+	mu       sync.RWMutex
+	handlers map[InvalidationType][]func(event InvalidationEvent)
+}
+
+// NewInvalidationBus creates a bus for the named service, every instance gets a unique origin to tell replicas apart in logs.
+func NewInvalidationBus(cache *Cache, service string) *InvalidationBus {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return &InvalidationBus{
+		cache:    cache,
+		origin:   service + "-" + hex.EncodeToString(suffix),
+		handlers: make(map[InvalidationType][]func(event InvalidationEvent)),
+	}
+}
+
+// On registers a handler for events of the given type.
+func (b *InvalidationBus) On(eventType InvalidationType, handler func(event InvalidationEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Listen subscribes to the events of all services. Handlers should be registered before.
+func (b *InvalidationBus) Listen() (*Subscription, error) {
+	return b.cache.Subscribe(invalidationChannel, func(message string) {
+		event := InvalidationEvent{}
+		err := json.Unmarshal([]byte(message), &event)
+		if err != nil {
+			log.WithField("event", "invalidation_decode").Error(err)
+			return
+		}
+
+		b.mu.RLock()
+		handlers := b.handlers[event.Type]
+		b.mu.RUnlock()
+		for _, handler := range handlers {
+			handler(event)
+		}
+	})
+}
+
+func (b *InvalidationBus) Publish(event InvalidationEvent) error {
+	event.Origin = b.origin
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.cache.Publish(invalidationChannel, string(message))
+}
+
+func (b *InvalidationBus) PublishTwitchChannelChanged(twitchUserId string) error {
+	return b.Publish(InvalidationEvent{Type: TwitchChannelChanged, TwitchUserId: twitchUserId})
+}
+
+// InvalidateTwitchChannel clears the cached settings and commands of a channel and publishes the change.
+func (b *InvalidationBus) InvalidateTwitchChannel(twitchUserId string) error {
+	b.cache.Delete(TwitchChannelKey(twitchUserId))
+	deleteErr := b.cache.DeleteMatching(TwitchCommandKey(twitchUserId, "*"))
+	// the event is published anyway so other instances do not keep their copies
+	err := b.PublishTwitchChannelChanged(twitchUserId)
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return err
+}
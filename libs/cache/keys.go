@@ -0,0 +1,15 @@
+package cache
+
+// TwitchBuiltinCommandsKey holds the names and aliases of the twitchbot's management commands as a json array.
+// The twitchbot writes it on startup, the api keeps channel commands from using these names.
+const TwitchBuiltinCommandsKey = "twitch:builtin_commands"
+
+// TwitchChannelKey is the key the settings of a channel are cached under, the other keys of the channel start with it.
+func TwitchChannelKey(twitchUserId string) string {
+	return "twitch:id:" + twitchUserId
+}
+
+// TwitchCommandKey is the key a command of a channel is cached under.
+func TwitchCommandKey(twitchUserId string, name string) string {
+	return TwitchChannelKey(twitchUserId) + ":cmd:" + name
+}
@@ -2,6 +2,8 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"github.com/go-redis/redis/v8"
 	"time"
 )
@@ -30,6 +32,11 @@ func (c *Cache) SetWithTtl(key string, value string, ttl time.Duration) error {
 	return c.redis.Set(c.ctx, key, value, ttl).Err()
 }
 
+// SetIfAbsent sets the key only if it does not exist yet and reports whether it was set.
+func (c *Cache) SetIfAbsent(key string, value string, ttl time.Duration) (bool, error) {
+	return c.redis.SetNX(c.ctx, key, value, ttl).Result()
+}
+
 func (c *Cache) SetKeepTtl(key string, value string) error {
 	return c.redis.Do(c.ctx, "set", key, value, "keepttl").Err()
 }
@@ -37,3 +44,118 @@ func (c *Cache) SetKeepTtl(key string, value string) error {
 func (c *Cache) Delete(key string) {
 	c.redis.Del(c.ctx, key)
 }
+
+// Consume deletes the key and reports whether it existed. Only one of several concurrent callers gets true.
+func (c *Cache) Consume(key string) (bool, error) {
+	deleted, err := c.redis.Del(c.ctx, key).Result()
+	return deleted > 0, err
+}
+
+// DeleteMatching deletes all keys matching the given glob pattern.
+func (c *Cache) DeleteMatching(pattern string) error {
+	iter := c.redis.Scan(c.ctx, 0, pattern, 100).Iterator()
+	for iter.Next(c.ctx) {
+		err := c.redis.Del(c.ctx, iter.Val()).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+var cooldownScript = redis.NewScript(`
+local remaining = 0
+for _, key in ipairs(KEYS) do
+	local ttl = redis.call("pttl", key)
+	if ttl > remaining then remaining = ttl end
+end
+if remaining > 0 then return remaining end
+for i, key in ipairs(KEYS) do
+	redis.call("set", key, "1", "px", ARGV[i])
+end
+return 0`)
+
+// StartCooldowns atomically starts all given cooldowns unless at least one of them is still running.
+// It returns the longest remaining time of the running cooldowns, or 0 if the cooldowns were started.
+func (c *Cache) StartCooldowns(cooldowns map[string]time.Duration) (time.Duration, error) {
+	keys := make([]string, 0, len(cooldowns))
+	ttls := make([]interface{}, 0, len(cooldowns))
+	for key, ttl := range cooldowns {
+		if ttl <= 0 {
+			continue
+		}
+		keys = append(keys, key)
+		ttls = append(ttls, ttl.Milliseconds())
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	remaining, err := cooldownScript.Run(c.ctx, c.redis, keys, ttls...).Int64()
+	return time.Duration(remaining) * time.Millisecond, err
+}
+
+var rateLimitScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	local count = tonumber(redis.call("get", key) or "0")
+	if count >= tonumber(ARGV[i * 2 - 1]) then return 0 end
+end
+for i, key in ipairs(KEYS) do
+	if redis.call("incr", key) == 1 then
+		redis.call("pexpire", key, ARGV[i * 2])
+	end
+end
+return 1`)
+
+// RateWindow allows Limit events per Window, counted under Key by all clients of the same redis instance.
+type RateWindow struct {
+	Key    string
+	Limit  int
+	Window time.Duration
+}
+
+// TakeRateLimit atomically counts one event in every window unless at least one of them is exhausted.
+// It reports whether the event was counted.
+func (c *Cache) TakeRateLimit(windows ...RateWindow) (bool, error) {
+	if len(windows) == 0 {
+		return true, nil
+	}
+	keys := make([]string, 0, len(windows))
+	args := make([]interface{}, 0, len(windows)*2)
+	for _, window := range windows {
+		keys = append(keys, window.Key)
+		args = append(args, window.Limit, window.Window.Milliseconds())
+	}
+
+	taken, err := rateLimitScript.Run(c.ctx, c.redis, keys, args...).Int()
+	return taken == 1, err
+}
+
+var unlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
+
+// TryLock acquires a short-lived lock shared by all clients of the same redis instance.
+// The returned token has to be passed to Unlock, an empty token means the lock is held by someone else.
+func (c *Cache) TryLock(key string, ttl time.Duration) (string, error) {
+	tokenBytes := make([]byte, 16)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	acquired, err := c.redis.SetNX(c.ctx, "lock:"+key, token, ttl).Result()
+	if err != nil || !acquired {
+		return "", err
+	}
+	return token, nil
+}
+
+// Unlock releases a lock acquired by TryLock if it is still owned by the given token.
+func (c *Cache) Unlock(key string, token string) error {
+	return unlockScript.Run(c.ctx, c.redis, []string{"lock:" + key}, token).Err()
+}
+
+func (c *Cache) IsLocked(key string) (bool, error) {
+	count, err := c.redis.Exists(c.ctx, "lock:"+key).Result()
+	return count > 0, err
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"github.com/go-redis/redis/v8"
+)
+
+// Subscription delivers the messages of a redis pub/sub channel until it is closed.
+type Subscription struct {
+	pubsub *redis.PubSub
+}
+
+func (c *Cache) Publish(channel string, message string) error {
+	return c.redis.Publish(c.ctx, channel, message).Err()
+}
+
+// Subscribe calls the handler for every message published to the channel. Handlers run one after another.
+// The connection is re-established automatically, messages published while it is down are lost.
+func (c *Cache) Subscribe(channel string, handler func(message string)) (*Subscription, error) {
+	pubsub := c.redis.Subscribe(c.ctx, channel)
+	// wait for the confirmation so no message published after Subscribe returns is missed
+	_, err := pubsub.Receive(c.ctx)
+	if err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			handler(msg.Payload)
+		}
+	}()
+	return &Subscription{pubsub: pubsub}, nil
+}
+
+func (s *Subscription) Close() error {
+	return s.pubsub.Close()
+}
@@ -0,0 +1,948 @@
+// Package rankformat implements the template language of rank message formats, shared by the twitchbot
+// rendering them and the api validating them. Everything outside of $(...) is printed as is.
+//
+//	$(2.r.s)                          value of a token, see evalPath for all tokens
+//	$(2.m | default "-")              tokens can be piped through filters
+//	$(if 3.r > 0)...$(else)...$(end)  conditionals, supporting not, and, or, ==, !=, <, <=, >, >=
+//	$(each ranked | sort mmr | sep " | ")$(it.playlist): $(it.r)$(end)
+//	                                  iterates over playlists, the current one is available as it
+//	$(reply)                          at the very start, answers the chatter in a thread
+//
+// Rank announcements can additionally use the changed playlist and its rank before and after the change:
+// $(playlist), $(rank), $(div), $(mmr), $(oldrank), $(olddiv), $(oldmmr) and $(direction), which is up or down.
+package rankformat
+
+import (
+	"fmt"
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const MaxFormatLength = 500
+const maxTemplateDepth = 4
+
+// TemplateError describes why a format was rejected. Part is the word of the token that could not be parsed.
+type TemplateError struct {
+	Token   string
+	Part    string
+	Message string
+}
+
+func (t TemplateError) Error() string {
+	if t.Token == "" {
+		return t.Message
+	}
+	if t.Part != "" && t.Part != t.Token {
+		return t.Message + " in " + t.Part + ": $(" + t.Token + ")"
+	}
+	return t.Message + ": $(" + t.Token + ")"
+}
+
+// RankNamer returns the display name of a rank, the modifier is s, m or l for short, medium and long names.
+type RankNamer func(rank int, modifier string) string
+
+// Baselines are the mmr values deltas are computed against, by playlist.
+type Baselines struct {
+	Session map[trackernet.Playlist]int
+	Day     map[trackernet.Playlist]int
+}
+
+type Template struct {
+	nodes      []templateNode
+	Reply      bool
+	UsesDeltas bool
+}
+
+type renderContext struct {
+	response  *trackernet.GetRankResponse
+	baselines *Baselines
+	chatter   string
+	rankName  RankNamer
+	it        *trackernet.Ranking
+	change    *RankChange
+}
+
+// RankChange is the playlist a rank announcement is rendered for.
+type RankChange struct {
+	Playlist trackernet.Playlist
+	Before   trackernet.Ranking
+	Now      trackernet.Ranking
+	Up       bool
+}
+
+// parseScope holds what tokens are allowed at the current position of the format.
+type parseScope struct {
+	inEach       bool
+	announcement bool
+}
+
+type templateNode interface {
+	render(ctx *renderContext, out *strings.Builder)
+}
+
+type textNode string
+
+func (t textNode) render(_ *renderContext, out *strings.Builder) {
+	out.WriteString(string(t))
+}
+
+type exprNode struct {
+	raw     string
+	operand operand
+	filters []filter
+}
+
+func (e *exprNode) render(ctx *renderContext, out *strings.Builder) {
+	val := e.operand.eval(ctx)
+	for _, f := range e.filters {
+		val = f.apply(val)
+	}
+	if !val.ok {
+		out.WriteString("[no_data:" + e.raw + "]")
+		return
+	}
+	out.WriteString(val.str)
+}
+
+type ifNode struct {
+	cond      condition
+	then      []templateNode
+	otherwise []templateNode
+}
+
+func (i *ifNode) render(ctx *renderContext, out *strings.Builder) {
+	nodes := i.otherwise
+	if i.cond.eval(ctx) {
+		nodes = i.then
+	}
+	for _, node := range nodes {
+		node.render(ctx, out)
+	}
+}
+
+type eachNode struct {
+	source string
+	sortBy string
+	limit  int
+	sep    string
+	placed bool
+	body   []templateNode
+}
+
+var rankedPlaylists = []trackernet.Playlist{trackernet.Ranked1v1, trackernet.Ranked2v2, trackernet.Ranked3v3}
+var extraPlaylists = []trackernet.Playlist{trackernet.Hoops, trackernet.Rumble, trackernet.Dropshot, trackernet.Snowday}
+
+func (e *eachNode) render(ctx *renderContext, out *strings.Builder) {
+	rankings := make([]trackernet.Ranking, 0)
+	for _, ranking := range ctx.response.Rankings {
+		if e.placed && ranking.Rank == 0 {
+			continue
+		}
+		if e.source == "all" || (e.source == "ranked" && containsPlaylist(rankedPlaylists, ranking.Playlist)) ||
+			(e.source == "extra" && containsPlaylist(extraPlaylists, ranking.Playlist)) {
+			rankings = append(rankings, ranking)
+		}
+	}
+
+	switch e.sortBy {
+	case "mmr":
+		sort.SliceStable(rankings, func(i, j int) bool { return rankings[i].Mmr > rankings[j].Mmr })
+	case "rank":
+		sort.SliceStable(rankings, func(i, j int) bool {
+			if rankings[i].Rank == rankings[j].Rank {
+				return rankings[i].Division > rankings[j].Division
+			}
+			return rankings[i].Rank > rankings[j].Rank
+		})
+	}
+	if e.limit > 0 && len(rankings) > e.limit {
+		rankings = rankings[:e.limit]
+	}
+
+	outer := ctx.it
+	for i := range rankings {
+		if i > 0 {
+			out.WriteString(e.sep)
+		}
+		ctx.it = &rankings[i]
+		for _, node := range e.body {
+			node.render(ctx, out)
+		}
+	}
+	ctx.it = outer
+}
+
+func containsPlaylist(playlists []trackernet.Playlist, playlist trackernet.Playlist) bool {
+	for _, p := range playlists {
+		if p == playlist {
+			return true
+		}
+	}
+	return false
+}
+
+// value is the result of evaluating an operand. Numeric values like ranks keep their display string in str.
+type value struct {
+	str   string
+	num   int
+	isNum bool
+	ok    bool
+}
+
+func (v value) truthy() bool {
+	if !v.ok {
+		return false
+	}
+	if v.isNum {
+		return v.num != 0
+	}
+	return v.str != ""
+}
+
+func numValue(num int) value {
+	return value{str: strconv.Itoa(num), num: num, isNum: true, ok: true}
+}
+
+type operand interface {
+	eval(ctx *renderContext) value
+}
+
+type literalOperand struct {
+	val value
+}
+
+func (l *literalOperand) eval(_ *renderContext) value {
+	return l.val
+}
+
+type pathOperand struct {
+	playlist string
+	stat     string
+	modifier string
+	token    string
+}
+
+func (p *pathOperand) eval(ctx *renderContext) value {
+	return evalPath(ctx, p)
+}
+
+type announcementOperand struct {
+	name string
+}
+
+var announcementOperands = map[string]bool{
+	"playlist": true, "rank": true, "div": true, "mmr": true, "oldrank": true, "olddiv": true, "oldmmr": true, "direction": true,
+}
+
+func (a *announcementOperand) eval(ctx *renderContext) value {
+	change := ctx.change
+	if change == nil {
+		return value{}
+	}
+	switch a.name {
+	case "playlist":
+		return value{str: PlaylistNames[change.Playlist], ok: true}
+	case "rank":
+		return value{str: ctx.rankName(change.Now.Rank, "l"), num: change.Now.Rank, isNum: true, ok: true}
+	case "div":
+		return numValue(change.Now.Division + 1)
+	case "mmr":
+		return numValue(change.Now.Mmr)
+	case "oldrank":
+		return value{str: ctx.rankName(change.Before.Rank, "l"), num: change.Before.Rank, isNum: true, ok: true}
+	case "olddiv":
+		return numValue(change.Before.Division + 1)
+	case "oldmmr":
+		return numValue(change.Before.Mmr)
+	case "direction":
+		if change.Up {
+			return value{str: "up", ok: true}
+		}
+		return value{str: "down", ok: true}
+	}
+	return value{}
+}
+
+type filter struct {
+	name string
+	arg  string
+}
+
+func (f filter) apply(val value) value {
+	switch f.name {
+	case "default":
+		if !val.ok {
+			return value{str: f.arg, ok: true}
+		}
+	case "upper":
+		val.str = strings.ToUpper(val.str)
+	case "lower":
+		val.str = strings.ToLower(val.str)
+	case "sign":
+		if val.isNum {
+			val.str = fmt.Sprintf("%+d", val.num)
+		}
+	case "abs":
+		if val.isNum && val.num < 0 {
+			val.num = -val.num
+			val.str = strconv.Itoa(val.num)
+		}
+	}
+	return val
+}
+
+var filterArgs = map[string]bool{"default": true, "upper": false, "lower": false, "sign": false, "abs": false}
+
+type comparison struct {
+	negate bool
+	left   operand
+	op     string
+	right  operand
+}
+
+func (c *comparison) eval(ctx *renderContext) bool {
+	left := c.left.eval(ctx)
+	result := left.truthy()
+	if c.op != "" {
+		right := c.right.eval(ctx)
+		result = compareValues(left, c.op, right)
+	}
+	return result != c.negate
+}
+
+func compareValues(left value, op string, right value) bool {
+	if !left.ok || !right.ok {
+		return false
+	}
+	cmp := 0
+	if left.isNum && right.isNum {
+		cmp = left.num - right.num
+	} else {
+		cmp = strings.Compare(strings.ToLower(left.str), strings.ToLower(right.str))
+	}
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// condition is a disjunction of conjunctions, and binds stronger than or.
+type condition [][]*comparison
+
+func (c condition) eval(ctx *renderContext) bool {
+	for _, conjunction := range c {
+		result := true
+		for _, cmp := range conjunction {
+			if !cmp.eval(ctx) {
+				result = false
+				break
+			}
+		}
+		if result {
+			return true
+		}
+	}
+	return false
+}
+
+// templateFrame is an open block while parsing, nodes points to the branch new nodes are appended to.
+type templateFrame struct {
+	nodes *[]templateNode
+	ifN   *ifNode
+	each  *eachNode
+	token string
+}
+
+// Parse validates a rank message format and compiles it for rendering.
+func Parse(format string) (*Template, error) {
+	return parseTemplate(format, false, false)
+}
+
+// ParseAnnouncement validates a rank announcement format, which can also use the announcement tokens.
+func ParseAnnouncement(format string) (*Template, error) {
+	if strings.HasPrefix(format, "$(reply)") {
+		return nil, &TemplateError{Token: "reply", Message: "Announcements can not reply"}
+	}
+	return parseTemplate(format, false, true)
+}
+
+// ParseStored compiles a format that was saved before, possibly by an older version of the bot.
+// Like the old token substitution it prints unknown tokens and a stray $( as is instead of failing.
+func ParseStored(format string) *Template {
+	tmpl, err := parseTemplate(format, true, false)
+	if err != nil {
+		return &Template{nodes: []templateNode{textNode(format)}}
+	}
+	return tmpl
+}
+
+func parseTemplate(format string, lenient bool, announcement bool) (*Template, error) {
+	if utf8.RuneCountInString(format) > MaxFormatLength {
+		return nil, &TemplateError{Message: "The format can be at most " + strconv.Itoa(MaxFormatLength) + " characters long"}
+	}
+
+	tmpl := Template{}
+	if strings.HasPrefix(format, "$(reply)") {
+		tmpl.Reply = true
+		format = strings.TrimPrefix(format, "$(reply)")
+	}
+
+	root := make([]templateNode, 0)
+	stack := []*templateFrame{{nodes: &root}}
+
+	rest := format
+	for {
+		start := strings.Index(rest, "$(")
+		if start < 0 {
+			break
+		}
+		if start > 0 {
+			top := stack[len(stack)-1]
+			*top.nodes = append(*top.nodes, textNode(rest[:start]))
+		}
+
+		top := stack[len(stack)-1]
+		end := findActionEnd(rest, start+2)
+		if end < 0 {
+			if lenient {
+				rest = rest[start:]
+				break
+			}
+			return nil, &TemplateError{Token: strings.TrimPrefix(rest[start:], "$("), Message: "Unclosed token"}
+		}
+		raw := rest[start : end+1]
+		token := strings.TrimSpace(rest[start+2 : end])
+		rest = rest[end+1:]
+
+		words, err := lexAction(token)
+		if err == nil && len(words) == 0 {
+			err = &TemplateError{Token: token, Message: "Empty token"}
+		}
+		if err != nil {
+			if lenient {
+				*top.nodes = append(*top.nodes, textNode(raw))
+				continue
+			}
+			return nil, err
+		}
+
+		switch words[0] {
+		case "if":
+			cond, err := parseCondition(token, words[1:], parseScope{inEach: insideEach(stack), announcement: announcement})
+			if err != nil {
+				return nil, err
+			}
+			node := &ifNode{cond: cond}
+			*top.nodes = append(*top.nodes, node)
+			stack = append(stack, &templateFrame{nodes: &node.then, ifN: node, token: token})
+		case "else":
+			if top.ifN == nil || len(words) != 1 || top.nodes == &top.ifN.otherwise {
+				return nil, &TemplateError{Token: token, Message: "else without matching if"}
+			}
+			top.nodes = &top.ifN.otherwise
+		case "end":
+			if len(stack) == 1 || len(words) != 1 {
+				return nil, &TemplateError{Token: token, Message: "end without matching if or each"}
+			}
+			stack = stack[:len(stack)-1]
+		case "each":
+			node, err := parseEach(token, words[1:])
+			if err != nil {
+				return nil, err
+			}
+			*top.nodes = append(*top.nodes, node)
+			stack = append(stack, &templateFrame{nodes: &node.body, each: node, token: token})
+		case "reply":
+			return nil, &TemplateError{Token: token, Message: "reply is only allowed at the start of the format"}
+		default:
+			node, err := parseExpr(token, words, parseScope{inEach: insideEach(stack), announcement: announcement})
+			if err != nil {
+				if lenient {
+					*top.nodes = append(*top.nodes, textNode(raw))
+					continue
+				}
+				return nil, err
+			}
+			*top.nodes = append(*top.nodes, node)
+		}
+
+		if len(stack) > maxTemplateDepth+1 {
+			return nil, &TemplateError{Token: token, Message: "Too many nested blocks"}
+		}
+	}
+
+	if len(stack) > 1 {
+		return nil, &TemplateError{Token: stack[len(stack)-1].token, Message: "Missing $(end) for"}
+	}
+	if rest != "" {
+		*stack[0].nodes = append(*stack[0].nodes, textNode(rest))
+	}
+
+	tmpl.nodes = root
+	tmpl.UsesDeltas = usesDeltas(root)
+	return &tmpl, nil
+}
+
+// usesDeltas reports whether any token of the nodes needs the mmr baselines.
+func usesDeltas(nodes []templateNode) bool {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *exprNode:
+			if isDeltaOperand(n.operand) {
+				return true
+			}
+		case *ifNode:
+			for _, conjunction := range n.cond {
+				for _, cmp := range conjunction {
+					if isDeltaOperand(cmp.left) || isDeltaOperand(cmp.right) {
+						return true
+					}
+				}
+			}
+			if usesDeltas(n.then) || usesDeltas(n.otherwise) {
+				return true
+			}
+		case *eachNode:
+			if usesDeltas(n.body) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isDeltaOperand(op operand) bool {
+	path, ok := op.(*pathOperand)
+	return ok && path.stat == "md"
+}
+
+func (t *Template) Render(response *trackernet.GetRankResponse, baselines *Baselines, chatter string, rankName RankNamer) string {
+	ctx := renderContext{response: response, baselines: baselines, chatter: chatter, rankName: rankName}
+	return t.render(&ctx)
+}
+
+// RenderAnnouncement renders an announcement format for a changed rank of the player.
+func (t *Template) RenderAnnouncement(response *trackernet.GetRankResponse, baselines *Baselines, change *RankChange, rankName RankNamer) string {
+	ctx := renderContext{response: response, baselines: baselines, change: change, rankName: rankName}
+	return t.render(&ctx)
+}
+
+func (t *Template) render(ctx *renderContext) string {
+	var out strings.Builder
+	for _, node := range t.nodes {
+		node.render(ctx, &out)
+	}
+	return out.String()
+}
+
+// findActionEnd returns the index of the closing parenthesis of a token, ignoring parentheses in string literals.
+func findActionEnd(s string, from int) int {
+	inString := false
+	for i := from; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case ')':
+			if !inString {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func lexAction(token string) ([]string, error) {
+	words := make([]string, 0)
+	runes := []rune(token)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch {
+		case unicode.IsSpace(ch):
+			continue
+		case ch == '"':
+			var str strings.Builder
+			str.WriteRune('"')
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				str.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, &TemplateError{Token: token, Message: "Unclosed string"}
+			}
+			words = append(words, str.String())
+		case ch == '|':
+			words = append(words, "|")
+		case strings.ContainsRune("=!<>", ch):
+			op := string(ch)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+				i++
+			}
+			if op == "=" || op == "!" {
+				return nil, &TemplateError{Token: token, Message: "Unknown operator " + op}
+			}
+			words = append(words, op)
+		case ch == '_' || ch == '.' || ch == '-' || unicode.IsLetter(ch) || unicode.IsDigit(ch):
+			start := i
+			for i+1 < len(runes) && (runes[i+1] == '_' || runes[i+1] == '.' || unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])) {
+				i++
+			}
+			words = append(words, string(runes[start:i+1]))
+		default:
+			return nil, &TemplateError{Token: token, Message: "Unexpected character " + string(ch)}
+		}
+	}
+	return words, nil
+}
+
+func insideEach(stack []*templateFrame) bool {
+	for _, f := range stack {
+		if f.each != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func parseExpr(token string, words []string, scope parseScope) (*exprNode, error) {
+	parts := splitPipes(words)
+	if len(parts[0]) != 1 {
+		return nil, &TemplateError{Token: token, Message: "Unknown token"}
+	}
+	op, err := parseOperand(token, parts[0][0], scope)
+	if err != nil {
+		return nil, err
+	}
+
+	node := exprNode{raw: token, operand: op}
+	for _, part := range parts[1:] {
+		if len(part) == 0 {
+			return nil, &TemplateError{Token: token, Message: "Missing filter name"}
+		}
+		takesArg, ok := filterArgs[part[0]]
+		if !ok {
+			return nil, &TemplateError{Token: token, Message: "Unknown filter " + part[0]}
+		}
+		f := filter{name: part[0]}
+		if takesArg {
+			if len(part) != 2 || !strings.HasPrefix(part[1], "\"") {
+				return nil, &TemplateError{Token: token, Message: "Filter " + part[0] + " needs a quoted argument"}
+			}
+			f.arg = strings.TrimPrefix(part[1], "\"")
+		} else if len(part) != 1 {
+			return nil, &TemplateError{Token: token, Message: "Filter " + part[0] + " takes no argument"}
+		}
+		node.filters = append(node.filters, f)
+	}
+	if len(node.filters) == 0 {
+		// keep the legacy behaviour of showing the plain token for missing data
+		if path, ok := op.(*pathOperand); ok {
+			node.raw = path.token
+		}
+	}
+	return &node, nil
+}
+
+func parseCondition(token string, words []string, scope parseScope) (condition, error) {
+	if len(words) == 0 {
+		return nil, &TemplateError{Token: token, Message: "if needs a condition"}
+	}
+
+	cond := condition{{}}
+	for i := 0; i < len(words); {
+		cmp := comparison{}
+		if words[i] == "not" {
+			cmp.negate = true
+			i++
+		}
+		if i >= len(words) {
+			return nil, &TemplateError{Token: token, Message: "Incomplete condition"}
+		}
+		left, err := parseOperand(token, words[i], scope)
+		if err != nil {
+			return nil, err
+		}
+		cmp.left = left
+		i++
+
+		if i < len(words) && isComparisonOp(words[i]) {
+			if i+1 >= len(words) {
+				return nil, &TemplateError{Token: token, Message: "Incomplete condition"}
+			}
+			cmp.op = words[i]
+			right, err := parseOperand(token, words[i+1], scope)
+			if err != nil {
+				return nil, err
+			}
+			cmp.right = right
+			i += 2
+		}
+		cond[len(cond)-1] = append(cond[len(cond)-1], &cmp)
+
+		if i < len(words) {
+			switch words[i] {
+			case "and":
+			case "or":
+				cond = append(cond, []*comparison{})
+			default:
+				return nil, &TemplateError{Token: token, Message: "Expected and/or instead of " + words[i]}
+			}
+			i++
+			if i >= len(words) {
+				return nil, &TemplateError{Token: token, Message: "Incomplete condition"}
+			}
+		}
+	}
+	return cond, nil
+}
+
+func isComparisonOp(word string) bool {
+	switch word {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+func parseEach(token string, words []string) (*eachNode, error) {
+	parts := splitPipes(words)
+	if len(parts[0]) != 1 {
+		return nil, &TemplateError{Token: token, Message: "each needs one of all, ranked or extra"}
+	}
+	node := eachNode{source: parts[0][0]}
+	switch node.source {
+	case "all", "ranked", "extra":
+	default:
+		return nil, &TemplateError{Token: token, Message: "each needs one of all, ranked or extra"}
+	}
+
+	for _, part := range parts[1:] {
+		if len(part) == 0 {
+			return nil, &TemplateError{Token: token, Message: "Missing filter name"}
+		}
+		switch {
+		case part[0] == "sort" && len(part) == 2 && (part[1] == "mmr" || part[1] == "rank"):
+			node.sortBy = part[1]
+		case part[0] == "limit" && len(part) == 2:
+			limit, err := strconv.Atoi(part[1])
+			if err != nil || limit <= 0 {
+				return nil, &TemplateError{Token: token, Message: "limit needs a positive number"}
+			}
+			node.limit = limit
+		case part[0] == "sep" && len(part) == 2 && strings.HasPrefix(part[1], "\""):
+			node.sep = strings.TrimPrefix(part[1], "\"")
+		case part[0] == "placed" && len(part) == 1:
+			node.placed = true
+		default:
+			return nil, &TemplateError{Token: token, Message: "Unknown each filter " + strings.Join(part, " ") +
+				", use sort mmr|rank, limit n, sep \"text\" or placed"}
+		}
+	}
+	return &node, nil
+}
+
+func splitPipes(words []string) [][]string {
+	parts := [][]string{{}}
+	for _, word := range words {
+		if word == "|" {
+			parts = append(parts, []string{})
+			continue
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], word)
+	}
+	return parts
+}
+
+var playlistAbbrs = "u123hrdst"
+
+func parseOperand(token string, word string, scope parseScope) (operand, error) {
+	if strings.HasPrefix(word, "\"") {
+		return &literalOperand{val: value{str: strings.TrimPrefix(word, "\""), ok: true}}, nil
+	}
+	if num, err := strconv.Atoi(word); err == nil {
+		return &literalOperand{val: numValue(num)}, nil
+	}
+
+	if word == "name" || word == "user" {
+		return &pathOperand{stat: word, token: word}, nil
+	}
+	if announcementOperands[word] {
+		if !scope.announcement {
+			return nil, &TemplateError{Token: token, Part: word, Message: word + " can only be used in rank announcements"}
+		}
+		return &announcementOperand{name: word}, nil
+	}
+
+	parts := strings.Split(word, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, &TemplateError{Token: token, Part: word, Message: "Unknown token"}
+	}
+	path := pathOperand{playlist: parts[0], stat: parts[1], token: word}
+	if len(parts) == 3 {
+		path.modifier = parts[2]
+	} else if len(path.stat) == 2 && strings.ContainsRune("rdm", rune(path.stat[0])) && strings.ContainsRune("sml", rune(path.stat[1])) {
+		// older formats could leave out the second dot, like $(2.rs)
+		path.stat, path.modifier = path.stat[:1], path.stat[1:]
+	}
+
+	if path.playlist == "it" {
+		if !scope.inEach {
+			return nil, &TemplateError{Token: token, Part: word, Message: "it can only be used inside of each"}
+		}
+	} else if len(path.playlist) != 1 || !strings.Contains(playlistAbbrs, path.playlist) {
+		return nil, &TemplateError{Token: token, Part: word, Message: "Unknown playlist " + path.playlist}
+	}
+
+	switch path.stat {
+	case "r", "d":
+		if path.modifier == "" {
+			path.modifier = "l"
+		}
+		if path.modifier != "s" && path.modifier != "m" && path.modifier != "l" {
+			return nil, &TemplateError{Token: token, Part: word, Message: "Unknown modifier " + path.modifier}
+		}
+	case "m":
+		// older formats accepted and ignored a modifier on the mmr
+		if path.modifier != "" && path.modifier != "s" && path.modifier != "m" && path.modifier != "l" {
+			return nil, &TemplateError{Token: token, Part: word, Message: "Unknown modifier " + path.modifier}
+		}
+		path.modifier = ""
+	case "playlist":
+		if path.modifier != "" {
+			return nil, &TemplateError{Token: token, Part: word, Message: "Unknown modifier " + path.modifier}
+		}
+	case "md":
+		if path.modifier != "session" && path.modifier != "24h" {
+			return nil, &TemplateError{Token: token, Part: word, Message: "md needs session or 24h"}
+		}
+	default:
+		return nil, &TemplateError{Token: token, Part: word, Message: "Unknown stat " + path.stat}
+	}
+	return &path, nil
+}
+
+// PlaylistNames are the display names of the playlists.
+var PlaylistNames = map[trackernet.Playlist]string{
+	trackernet.Unranked: "Casual", trackernet.Ranked1v1: "1v1", trackernet.Ranked2v2: "2v2", trackernet.Ranked3v3: "3v3",
+	trackernet.Hoops: "Hoops", trackernet.Rumble: "Rumble", trackernet.Dropshot: "Dropshot", trackernet.Snowday: "Snowday",
+	trackernet.Tournaments: "Tournaments",
+}
+
+// evalPath resolves name, user and <playlist>.<stat>[.<modifier>] tokens. Playlists are u, 1, 2, 3, h, r, d, s, t
+// or it inside of each, stats are r (rank), d (division), m (mmr), md (mmr delta) and playlist.
+func evalPath(ctx *renderContext, path *pathOperand) value {
+	switch path.stat {
+	case "name":
+		return value{str: ctx.response.DisplayName, ok: true}
+	case "user":
+		return value{str: ctx.chatter, ok: true}
+	}
+
+	var ranking *trackernet.Ranking
+	if path.playlist == "it" {
+		ranking = ctx.it
+	} else {
+		playlist := playlistFromAbbr(path.playlist)
+		for i := range ctx.response.Rankings {
+			if ctx.response.Rankings[i].Playlist == playlist {
+				ranking = &ctx.response.Rankings[i]
+				break
+			}
+		}
+	}
+	if ranking == nil {
+		return value{}
+	}
+
+	switch path.stat {
+	case "r":
+		return value{str: ctx.rankName(ranking.Rank, path.modifier), num: ranking.Rank, isNum: true, ok: true}
+	case "d":
+		val := numValue(ranking.Division + 1)
+		if path.modifier == "l" || path.modifier == "m" {
+			val.str = ToRoman(ranking.Division + 1)
+		}
+		return val
+	case "m":
+		return numValue(ranking.Mmr)
+	case "playlist":
+		return value{str: PlaylistNames[ranking.Playlist], ok: true}
+	case "md":
+		if ctx.baselines == nil {
+			return value{}
+		}
+		baseline := ctx.baselines.Session
+		if path.modifier == "24h" {
+			baseline = ctx.baselines.Day
+		}
+		baselineMmr, ok := baseline[ranking.Playlist]
+		if !ok {
+			return value{}
+		}
+		val := numValue(ranking.Mmr - baselineMmr)
+		val.str = fmt.Sprintf("%+d", val.num)
+		return val
+	}
+	return value{}
+}
+
+func playlistFromAbbr(abbr string) trackernet.Playlist {
+	switch abbr {
+	case "u":
+		return trackernet.Unranked
+	case "1":
+		return trackernet.Ranked1v1
+	case "2":
+		return trackernet.Ranked2v2
+	case "3":
+		return trackernet.Ranked3v3
+	case "h":
+		return trackernet.Hoops
+	case "r":
+		return trackernet.Rumble
+	case "d":
+		return trackernet.Dropshot
+	case "s":
+		return trackernet.Snowday
+	case "t":
+		return trackernet.Tournaments
+	}
+	return trackernet.Unranked
+}
+
+// ToRoman returns the roman numeral of a division.
+func ToRoman(num int) string {
+	switch num {
+	case 1:
+		return "I"
+	case 2:
+		return "II"
+	case 3:
+		return "III"
+	case 4:
+		return "IV"
+	}
+	return "?"
+}
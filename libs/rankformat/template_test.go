@@ -0,0 +1,207 @@
+package rankformat
+
+import (
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"strings"
+	"testing"
+)
+
+var testRanks = &trackernet.GetRankResponse{
+	DisplayName: "Player",
+	Rankings: []trackernet.Ranking{
+		{Playlist: trackernet.Ranked1v1, Mmr: 900, Rank: 13, Division: 1},
+		{Playlist: trackernet.Ranked2v2, Mmr: 1500, Rank: 19, Division: 3},
+		{Playlist: trackernet.Ranked3v3, Mmr: 1200, Rank: 16, Division: 0},
+		{Playlist: trackernet.Hoops, Mmr: 600, Rank: 0, Division: 0},
+	},
+}
+
+// testRankNames are the short, medium and long names of the ranks used in testRanks.
+var testRankNames = map[int][3]string{
+	0:  {"U", "Unranked", "Unranked"},
+	13: {"D1", "Dia I", "Diamond I"},
+	16: {"C1", "Champ I", "Champion I"},
+	19: {"GC1", "GC I", "Grand Champion I"},
+}
+
+func testRankNamer(rank int, modifier string) string {
+	names := testRankNames[rank]
+	switch modifier {
+	case "s":
+		return names[0]
+	case "m":
+		return names[1]
+	}
+	return names[2]
+}
+
+var testBaselines = &Baselines{
+	Session: map[trackernet.Playlist]int{trackernet.Ranked2v2: 1480, trackernet.Ranked3v3: 1250},
+	Day:     map[trackernet.Playlist]int{trackernet.Ranked2v2: 1400},
+}
+
+func TestTemplateRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"plain text", "no tokens here", "no tokens here"},
+		{"rank and division", "$(2.r) $(2.d)", "Grand Champion I IV"},
+		{"short rank", "$(2.r.s) $(2.d.s)", "GC1 4"},
+		{"mmr", "$(3.m)", "1200"},
+		{"name and user", "$(name) asked by $(user)", "Player asked by chatter"},
+		{"missing playlist", "$(t.m)", "[no_data:t.m]"},
+		{"default filter", "$(t.m | default \"-\")", "-"},
+		{"conditional", "$(if 1.m > 1000)high$(else)low$(end)", "low"},
+		{"and or", "$(if 1.m > 1000 or 2.m > 1000 and not h.r)yes$(end)", "yes"},
+		{"each sorted", "$(each ranked | sort mmr | sep \", \")$(it.playlist) $(it.m)$(end)", "2v2 1500, 3v3 1200, 1v1 900"},
+		{"each placed limit", "$(each all | placed | limit 1)$(it.r.s)$(end)", "D1"},
+		{"session delta", "$(2.md.session) $(3.md.session)", "+20 -50"},
+		{"24h delta", "$(2.md.24h)", "+100"},
+		{"delta filter", "$(3.md.session | abs)", "50"},
+		{"missing baseline", "$(1.md.24h | default \"new\")", "new"},
+		{"delta in condition", "$(if 2.md.session > 0)up$(end)", "up"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := Parse(test.format)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.format, err)
+			}
+			got := tmpl.Render(testRanks, testBaselines, "chatter", testRankNamer)
+			if got != test.want {
+				t.Errorf("Render(%q) = %q, want %q", test.format, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTemplateUsesDeltas(t *testing.T) {
+	tests := []struct {
+		format string
+		want   bool
+	}{
+		{"$(2.m)", false},
+		{"$(2.md.session)", true},
+		{"$(if 2.md.24h > 0)up$(end)", true},
+		{"$(each ranked)$(it.md.session)$(end)", true},
+		{"$(2.m) \"3.md.session\"", false},
+		{"$(2.m | default \".md.\")", false},
+	}
+	for _, test := range tests {
+		tmpl, err := Parse(test.format)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", test.format, err)
+		}
+		if tmpl.UsesDeltas != test.want {
+			t.Errorf("Parse(%q).UsesDeltas = %v, want %v", test.format, tmpl.UsesDeltas, test.want)
+		}
+	}
+}
+
+func TestTemplateParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		token  string
+		part   string
+	}{
+		{"unclosed token", "$(2.r", "2.r", ""},
+		{"empty token", "$( )", "", ""},
+		{"unknown playlist", "$(x.r)", "x.r", "x.r"},
+		{"unknown stat", "$(2.q)", "2.q", "2.q"},
+		{"unknown modifier", "$(2.r.x)", "2.r.x", "2.r.x"},
+		{"md without window", "$(2.md)", "2.md", "2.md"},
+		{"unknown operand in condition", "$(if 2.m > 3.x)y$(end)", "if 2.m > 3.x", "3.x"},
+		{"unknown filter", "$(2.m | shout)", "2.m | shout", ""},
+		{"filter without argument", "$(2.m | default)", "2.m | default", ""},
+		{"it outside of each", "$(it.m)", "it.m", "it.m"},
+		{"unknown each source", "$(each casual)$(end)", "each casual", ""},
+		{"missing end", "$(if 2.m > 0)x", "if 2.m > 0", ""},
+		{"end without block", "x$(end)", "end", ""},
+		{"else without if", "$(else)", "else", ""},
+		{"reply in the middle", "x$(reply)", "reply", ""},
+		{"unclosed string", "$(2.m | default \"-)", "2.m | default \"-)", ""},
+		{"too deep", "$(if 1.m)$(if 1.m)$(if 1.m)$(if 1.m)$(if 1.m)x$(end)$(end)$(end)$(end)$(end)", "if 1.m", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.format)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want an error", test.format)
+			}
+			tmplErr, ok := err.(*TemplateError)
+			if !ok {
+				t.Fatalf("Parse(%q) returned %T, want *TemplateError", test.format, err)
+			}
+			if tmplErr.Token != test.token || tmplErr.Part != test.part {
+				t.Errorf("Parse(%q) failed on token %q part %q, want %q part %q",
+					test.format, tmplErr.Token, tmplErr.Part, test.token, test.part)
+			}
+		})
+	}
+}
+
+func TestParseStored(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"legacy compact token", "$(2.rs) $(2.rl)", "GC1 Grand Champion I"},
+		{"legacy mmr modifier", "$(2.m.l) $(2.ms)", "1500 1500"},
+		{"unknown token stays literal", "$(2.m) $(foo)", "1500 $(foo)"},
+		{"stray opening", "price $(2.m) $(", "price 1500 $("},
+		{"reply prefix", "$(reply)$(name)", "Player"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ParseStored(test.format).Render(testRanks, nil, "chatter", testRankNamer)
+			if got != test.want {
+				t.Errorf("ParseStored(%q) rendered %q, want %q", test.format, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFormatLength(t *testing.T) {
+	// the limit counts characters, not bytes
+	atLimit := strings.Repeat("é", MaxFormatLength)
+	if _, err := Parse(atLimit); err != nil {
+		t.Errorf("ParseTemplate of %d characters failed: %v", MaxFormatLength, err)
+	}
+	if _, err := Parse(atLimit + "x"); err == nil {
+		t.Errorf("ParseTemplate of %d characters succeeded, want an error", MaxFormatLength+1)
+	}
+
+	// stored formats over the limit are printed as is
+	tooLong := atLimit + "$(2.m)"
+	if got := ParseStored(tooLong).Render(testRanks, nil, "chatter", testRankNamer); got != tooLong {
+		t.Errorf("ParseStoredTemplate of an oversized format rendered %q", got)
+	}
+}
+
+func TestRenderAnnouncement(t *testing.T) {
+	change := &RankChange{
+		Playlist: trackernet.Ranked2v2,
+		Before:   trackernet.Ranking{Playlist: trackernet.Ranked2v2, Mmr: 1420, Rank: 16, Division: 3},
+		Now:      trackernet.Ranking{Playlist: trackernet.Ranked2v2, Mmr: 1500, Rank: 19, Division: 0},
+		Up:       true,
+	}
+	tmpl, err := ParseAnnouncement("$(if direction == \"up\")GG$(end) $(playlist): $(oldrank) Div $(olddiv) -> $(rank) Div $(div) ($(mmr))")
+	if err != nil {
+		t.Fatalf("ParseAnnouncement failed: %v", err)
+	}
+	want := "GG 2v2: Champion I Div 4 -> Grand Champion I Div 1 (1500)"
+	if got := tmpl.RenderAnnouncement(testRanks, nil, change, testRankNamer); got != want {
+		t.Errorf("RenderAnnouncement = %q, want %q", got, want)
+	}
+
+	if _, err := Parse("$(rank)"); err == nil {
+		t.Error("Parse accepted an announcement token in a rank format")
+	}
+	if _, err := ParseAnnouncement("$(reply)$(rank)"); err == nil {
+		t.Error("ParseAnnouncement accepted $(reply)")
+	}
+}
@@ -1,5 +1,7 @@
 package trackernet
 
+import "time"
+
 type GetRankResponse struct {
 	DisplayName string    `json:"displayName"`
 	Rankings    []Ranking `json:"rankings"`
@@ -34,3 +36,15 @@ const (
 	Snowday     = "snowday"
 	Tournaments = "tournaments"
 )
+
+type GetHistoryResponse struct {
+	Entries []HistoryEntry `json:"entries"`
+}
+
+type HistoryEntry struct {
+	Playlist   Playlist  `json:"playlist"`
+	Mmr        int       `json:"mmr"`
+	Rank       int       `json:"rank"`
+	Division   int       `json:"division"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
@@ -1,5 +1,78 @@
 package webscraper
 
 type GetScrapeResponse struct {
-	Content string `json:"content"`
+	Content     string              `json:"content"`
+	Mode        Mode                `json:"mode"`
+	StatusCode  int                 `json:"statusCode,omitempty"`
+	FinalUrl    string              `json:"finalUrl"`
+	ContentType string              `json:"contentType,omitempty"`
+	Extraction  Extraction          `json:"extraction"`
+	Values      map[string][]string `json:"values,omitempty"`
+	Timings     ScrapeTimings       `json:"timings"`
 }
+
+// ScrapeTimings contains the duration of each scrape phase in milliseconds.
+type ScrapeTimings struct {
+	Queue   int64 `json:"queue"`
+	Fetch   int64 `json:"fetch"`
+	Extract int64 `json:"extract"`
+	Total   int64 `json:"total"`
+}
+
+type Mode string
+
+const (
+	ModeHttp    Mode = "http"
+	ModeBrowser Mode = "browser"
+	ModeAuto    Mode = "auto"
+)
+
+// Priority decides the order in which queued browser scrapes are executed.
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive"
+	PriorityDefault     Priority = "default"
+	PriorityBackground  Priority = "background"
+)
+
+type Extraction string
+
+const (
+	ExtractionBody       Extraction = "body"
+	ExtractionPre        Extraction = "pre"
+	ExtractionPageSource Extraction = "page_source"
+	ExtractionSelectors  Extraction = "selectors"
+)
+
+// ScrapeRequest can be posted to /scrape as JSON to extract specific elements from the rendered page.
+type ScrapeRequest struct {
+	Url         string     `json:"url"`
+	Mode        Mode       `json:"mode,omitempty"`
+	Priority    Priority   `json:"priority,omitempty"`
+	Selectors   []Selector `json:"selectors,omitempty"`
+	WaitFor     *Selector  `json:"waitFor,omitempty"`
+	WaitTimeout int        `json:"waitTimeout,omitempty"`
+}
+
+type Selector struct {
+	Name      string       `json:"name,omitempty"`
+	Type      SelectorType `json:"type,omitempty"`
+	Query     string       `json:"query"`
+	Attribute string       `json:"attribute,omitempty"`
+}
+
+// Key returns the key under which the selector's values are returned.
+func (s *Selector) Key() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Query
+}
+
+type SelectorType string
+
+const (
+	SelectorCss   SelectorType = "css"
+	SelectorXPath SelectorType = "xpath"
+)
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sessionTtl     = time.Hour * 24 * 7
+	oauthStateTtl  = time.Minute * 10
+	sessionCookie  = "dashboard_session"
+	stateCookie    = "dashboard_oauth_state"
+	sessionKeyBase = "dashboard:session:"
+	stateKeyBase   = "dashboard:state:"
+)
+
+// DashboardSession is the Twitch account a dashboard user logged in with.
+type DashboardSession struct {
+	TwitchUserId string `json:"id"`
+	TwitchLogin  string `json:"login"`
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// loginHandler redirects to Twitch to authorize the dashboard. No scopes are requested, the token only proves the identity.
+func loginHandler() http.Handler {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		state, err := randomToken()
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_login_state").Error(err)
+			return
+		}
+		err = redisCache.SetWithTtl(stateKeyBase+state, "1", oauthStateTtl)
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_login_state").Error(err)
+			return
+		}
+
+		// the state is bound to the browser that started the login, a callback with a state started elsewhere is rejected
+		http.SetCookie(rw, &http.Cookie{
+			Name:     stateCookie,
+			Value:    state,
+			Path:     "/dashboard/auth",
+			MaxAge:   int(oauthStateTtl.Seconds()),
+			HttpOnly: true,
+			Secure:   isSecureRequest(r),
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		query := url.Values{}
+		query.Set("client_id", configuration.TwitchClientId)
+		query.Set("redirect_uri", configuration.TwitchRedirectUrl)
+		query.Set("response_type", "code")
+		query.Set("scope", "")
+		query.Set("state", state)
+		http.Redirect(rw, r, configuration.TwitchAuthUrl+"/authorize?"+query.Encode(), http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// callbackHandler exchanges the authorization code for a token and starts a dashboard session for the Twitch account.
+func callbackHandler() http.Handler {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		state := r.URL.Query().Get("state")
+		code := r.URL.Query().Get("code")
+		if state == "" || code == "" {
+			rw.WriteHeader(400)
+			_, _ = rw.Write([]byte("Missing code or state"))
+			return
+		}
+		http.SetCookie(rw, &http.Cookie{Name: stateCookie, Value: "", Path: "/dashboard/auth", MaxAge: -1})
+		cookie, err := r.Cookie(stateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			rw.WriteHeader(403)
+			_, _ = rw.Write([]byte("Login was started in another browser, please try again"))
+			return
+		}
+		// consuming the state atomically makes sure it is only redeemed once
+		valid, err := redisCache.Consume(stateKeyBase + state)
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_login_state").Error(err)
+			return
+		}
+		if !valid {
+			rw.WriteHeader(403)
+			_, _ = rw.Write([]byte("Login expired, please try again"))
+			return
+		}
+
+		session, err := authenticateTwitchCode(code)
+		if err != nil {
+			rw.WriteHeader(403)
+			log.WithField("event", "dashboard_login").Warn(err)
+			_, _ = rw.Write([]byte("Twitch login failed"))
+			return
+		}
+
+		token, err := randomToken()
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_session").Error(err)
+			return
+		}
+		sessionStr, _ := json.Marshal(session)
+		err = redisCache.SetWithTtl(sessionKeyBase+token, string(sessionStr), sessionTtl)
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_session").Error(err)
+			return
+		}
+
+		log.WithField("event", "dashboard_login").WithField("login", session.TwitchLogin).Info("Dashboard login")
+		http.SetCookie(rw, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token,
+			Path:     "/dashboard",
+			MaxAge:   int(sessionTtl.Seconds()),
+			HttpOnly: true,
+			Secure:   isSecureRequest(r),
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(rw, r, configuration.DashboardUrl, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func logoutHandler() http.Handler {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.WriteHeader(405)
+			return
+		}
+		if token := sessionToken(r); token != "" {
+			redisCache.Delete(sessionKeyBase + token)
+		}
+		http.SetCookie(rw, &http.Cookie{Name: sessionCookie, Value: "", Path: "/dashboard", MaxAge: -1})
+		rw.WriteHeader(204)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// authenticateTwitchCode redeems an authorization code and validates the resulting token to get the Twitch account.
+func authenticateTwitchCode(code string) (*DashboardSession, error) {
+	form := url.Values{}
+	form.Set("client_id", configuration.TwitchClientId)
+	form.Set("client_secret", configuration.TwitchClientSecret)
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	form.Set("redirect_uri", configuration.TwitchRedirectUrl)
+
+	res, err := httpClient.PostForm(configuration.TwitchAuthUrl+"/token", form)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, errors.New("twitch token exchange responded with status " + res.Status)
+	}
+	var tokenRes struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&tokenRes)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", configuration.TwitchAuthUrl+"/validate", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "OAuth "+tokenRes.AccessToken)
+	req.Header.Set("User-Agent", "yannismate-api/services/api")
+	validateRes, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer validateRes.Body.Close()
+	if validateRes.StatusCode != 200 {
+		return nil, errors.New("twitch token validation responded with status " + validateRes.Status)
+	}
+	var validation struct {
+		ClientId string `json:"client_id"`
+		Login    string `json:"login"`
+		UserId   string `json:"user_id"`
+	}
+	err = json.NewDecoder(validateRes.Body).Decode(&validation)
+	if err != nil {
+		return nil, err
+	}
+	if validation.ClientId != configuration.TwitchClientId || validation.UserId == "" {
+		return nil, errors.New("twitch token was issued for a different client")
+	}
+
+	return &DashboardSession{TwitchUserId: validation.UserId, TwitchLogin: strings.ToLower(validation.Login)}, nil
+}
+
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func sessionToken(r *http.Request) string {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func getSession(r *http.Request) (*DashboardSession, bool) {
+	token := sessionToken(r)
+	if token == "" {
+		return nil, false
+	}
+	sessionStr, err := redisCache.Get(sessionKeyBase + token)
+	if err != nil {
+		return nil, false
+	}
+	session := DashboardSession{}
+	if json.Unmarshal([]byte(sessionStr), &session) != nil {
+		return nil, false
+	}
+	return &session, true
+}
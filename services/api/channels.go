@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/cache"
+	"github.com/yannismate/yannismate-api/libs/rankformat"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	minCommandCooldown = 10
+	maxCommandCooldown = 86400
+)
+
+var validPlatforms = map[string]bool{"epic": true, "steam": true, "ps": true, "xbox": true}
+
+// invalidateChannelCache makes the twitchbot reload the settings of a channel on the next command.
+func invalidateChannelCache(twitchUserId string) {
+	err := invalidationBus.InvalidateTwitchChannel(twitchUserId)
+	if err != nil {
+		log.WithField("event", "channel_cache_invalidate").Error(err)
+	}
+}
+
+func withSession(next func(rw http.ResponseWriter, r *http.Request, session *DashboardSession)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		session, ok := getSession(r)
+		if !ok {
+			rw.WriteHeader(401)
+			_, _ = rw.Write([]byte("Not logged in"))
+			return
+		}
+		next(rw, r, session)
+	})
+}
+
+func writeJson(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+func sessionHandler() http.Handler {
+	return withSession(func(rw http.ResponseWriter, r *http.Request, session *DashboardSession) {
+		writeJson(rw, 200, session)
+	})
+}
+
+// mayManageChannel reports whether the logged in user is the broadcaster or a delegate of the channel.
+func mayManageChannel(session *DashboardSession, twitchUserId string) (bool, error) {
+	if session.TwitchUserId == twitchUserId {
+		return true, nil
+	}
+	return apiDb.IsChannelDelegate(twitchUserId, session.TwitchUserId)
+}
+
+// channelsHandler serves GET on /dashboard/channels, the channels the logged in user can manage.
+func channelsHandler() http.Handler {
+	return withSession(func(rw http.ResponseWriter, r *http.Request, session *DashboardSession) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(405)
+			return
+		}
+		channels, err := apiDb.GetManagedChannels(session.TwitchUserId)
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_get_channels").Error(err)
+			return
+		}
+		writeJson(rw, 200, channels)
+	})
+}
+
+// channelHandler serves GET and PATCH on /dashboard/channels/<twitch user id>.
+func channelHandler() http.Handler {
+	return withSession(func(rw http.ResponseWriter, r *http.Request, session *DashboardSession) {
+		twitchUserId := strings.TrimPrefix(r.URL.Path, "/dashboard/channels/")
+		if twitchUserId == "" || strings.Contains(twitchUserId, "/") {
+			rw.WriteHeader(404)
+			return
+		}
+
+		allowed, err := mayManageChannel(session, twitchUserId)
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_channel_permission").Error(err)
+			return
+		}
+		if !allowed {
+			rw.WriteHeader(403)
+			_, _ = rw.Write([]byte("Not allowed to manage this channel"))
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			getChannel(rw, twitchUserId)
+		case http.MethodPatch:
+			updateChannel(rw, r, session, twitchUserId)
+		default:
+			rw.WriteHeader(405)
+		}
+	})
+}
+
+func getChannel(rw http.ResponseWriter, twitchUserId string) {
+	settings, err := apiDb.GetChannelSettings(twitchUserId)
+	if err == pgx.ErrNoRows {
+		rw.WriteHeader(404)
+		_, _ = rw.Write([]byte("The bot has not joined this channel"))
+		return
+	}
+	if err != nil {
+		rw.WriteHeader(500)
+		log.WithField("event", "dashboard_get_channel").Error(err)
+		return
+	}
+	writeJson(rw, 200, settings)
+}
+
+func updateChannel(rw http.ResponseWriter, r *http.Request, session *DashboardSession, twitchUserId string) {
+	// a json content type can not be sent by plain html forms, which keeps other sites from using the session cookie
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		rw.WriteHeader(415)
+		return
+	}
+	update := ChannelSettingsUpdate{}
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 1<<16))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		rw.WriteHeader(400)
+		_, _ = rw.Write([]byte("Invalid settings: " + err.Error()))
+		return
+	}
+
+	if msg := validateChannelSettings(&update); msg != "" {
+		rw.WriteHeader(400)
+		_, _ = rw.Write([]byte(msg))
+		return
+	}
+	if update.CommandName != nil {
+		builtin, err := isBuiltinCommand(strings.ToLower(*update.CommandName))
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_update_channel").Error(err)
+			return
+		}
+		if builtin {
+			rw.WriteHeader(409)
+			_, _ = rw.Write([]byte("A command of the bot already uses this name"))
+			return
+		}
+		taken, err := apiDb.IsChannelCommandTaken(twitchUserId, strings.ToLower(*update.CommandName))
+		if err != nil {
+			rw.WriteHeader(500)
+			log.WithField("event", "dashboard_update_channel").Error(err)
+			return
+		}
+		if taken {
+			rw.WriteHeader(409)
+			_, _ = rw.Write([]byte("Another command of the channel already uses this name"))
+			return
+		}
+	}
+
+	wasChanged, err := apiDb.UpdateChannelSettings(twitchUserId, update)
+	if err != nil {
+		rw.WriteHeader(500)
+		log.WithField("event", "dashboard_update_channel").Error(err)
+		return
+	}
+	if !wasChanged {
+		rw.WriteHeader(404)
+		_, _ = rw.Write([]byte("The bot has not joined this channel"))
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	log.WithField("event", "dashboard_update_channel").WithField("channel", twitchUserId).WithField("login", session.TwitchLogin).Info("Channel settings updated")
+	getChannel(rw, twitchUserId)
+}
+
+// isBuiltinCommand reports whether a management command of the twitchbot uses the name. Before the twitchbot
+// published its commands no name counts as taken.
+func isBuiltinCommand(name string) (bool, error) {
+	encoded, err := redisCache.Get(cache.TwitchBuiltinCommandsKey)
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	names := make([]string, 0)
+	err = json.Unmarshal([]byte(encoded), &names)
+	if err != nil {
+		return false, err
+	}
+	for _, builtin := range names {
+		if builtin == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateChannelSettings applies the limits the chat commands enforce and returns a message for the first invalid field.
+func validateChannelSettings(update *ChannelSettingsUpdate) string {
+	if update.RlPlatform != nil {
+		platform := strings.ToLower(*update.RlPlatform)
+		if !validPlatforms[platform] {
+			return "Valid platforms are epic, steam, ps and xbox"
+		}
+		update.RlPlatform = &platform
+	}
+	if update.RlUsername != nil && (*update.RlUsername == "" || len(*update.RlUsername) > 255) {
+		return "The username must be between 1 and 255 characters long"
+	}
+	if update.RlMessageFormat != nil {
+		if *update.RlMessageFormat == "" {
+			return "The format must not be empty"
+		}
+		if _, err := rankformat.Parse(*update.RlMessageFormat); err != nil {
+			return "Invalid format. " + err.Error()
+		}
+	}
+	if update.CommandName != nil {
+		name := strings.TrimPrefix(strings.TrimSpace(*update.CommandName), "!")
+		if name == "" || len(name) > 50 || strings.ContainsAny(name, " !") {
+			return "Command names can be at most 50 characters long and must not contain spaces"
+		}
+		update.CommandName = &name
+	}
+	if update.CommandCooldown != nil && (*update.CommandCooldown < minCommandCooldown || *update.CommandCooldown > maxCommandCooldown) {
+		return "The cooldown must be between " + strconv.Itoa(minCommandCooldown) + " and " + strconv.Itoa(maxCommandCooldown) + " seconds"
+	}
+	return ""
+}
@@ -4,4 +4,9 @@ type Configuration struct {
 	DbUri                string
 	CacheUrl             string
 	TrackerNetServiceUrl string
+	TwitchClientId       string `env:"TWITCH_CLIENT_ID"`
+	TwitchClientSecret   string `env:"TWITCH_CLIENT_SECRET"`
+	TwitchAuthUrl        string
+	TwitchRedirectUrl    string
+	DashboardUrl         string
 }
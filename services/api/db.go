@@ -43,3 +43,97 @@ func (db *ApiDb) GetApiUserByKey(apiKey string) (*ApiUser, error) {
 		RateLimit300: int(ratelimit300),
 	}, nil
 }
+
+// ChannelSettings are the settings of a twitchbot channel that can be managed through the dashboard.
+type ChannelSettings struct {
+	TwitchUserId    string `json:"twitchUserId"`
+	TwitchLogin     string `json:"twitchLogin"`
+	RlPlatform      string `json:"platform"`
+	RlUsername      string `json:"username"`
+	RlMessageFormat string `json:"format"`
+	CommandName     string `json:"command"`
+	CommandCooldown int    `json:"cooldown"`
+}
+
+// ChannelSettingsUpdate holds the settings to change, nil fields are left as they are.
+type ChannelSettingsUpdate struct {
+	RlPlatform      *string `json:"platform"`
+	RlUsername      *string `json:"username"`
+	RlMessageFormat *string `json:"format"`
+	CommandName     *string `json:"command"`
+	CommandCooldown *int    `json:"cooldown"`
+}
+
+// ManagedChannel is a channel the logged in user can manage, either their own or one they are a delegate of.
+type ManagedChannel struct {
+	TwitchUserId string `json:"twitchUserId"`
+	TwitchLogin  string `json:"twitchLogin"`
+	Delegated    bool   `json:"delegated"`
+}
+
+func (db *ApiDb) GetChannelSettings(twitchUserId string) (*ChannelSettings, error) {
+	settings := ChannelSettings{}
+	var platform, username *string
+	err := db.pool.QueryRow(db.ctx, `select twitch_user_id, twitch_login, rl_platform, rl_username, rl_message_format,
+		twitch_command_name, twitch_command_cooldown from users_twitch where twitch_user_id=$1;`, twitchUserId).
+		Scan(&settings.TwitchUserId, &settings.TwitchLogin, &platform, &username, &settings.RlMessageFormat,
+			&settings.CommandName, &settings.CommandCooldown)
+	if err != nil {
+		return nil, err
+	}
+	if platform != nil {
+		settings.RlPlatform = *platform
+	}
+	if username != nil {
+		settings.RlUsername = *username
+	}
+	return &settings, nil
+}
+
+func (db *ApiDb) UpdateChannelSettings(twitchUserId string, update ChannelSettingsUpdate) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_platform=coalesce($1, rl_platform),
+		rl_username=coalesce($2, rl_username), rl_message_format=coalesce($3, rl_message_format),
+		twitch_command_name=coalesce($4, twitch_command_name), twitch_command_cooldown=coalesce($5, twitch_command_cooldown)
+		where twitch_user_id=$6;`,
+		update.RlPlatform, update.RlUsername, update.RlMessageFormat, update.CommandName, update.CommandCooldown, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+// IsChannelCommandTaken reports whether an additional command of the channel already uses the name or alias.
+func (db *ApiDb) IsChannelCommandTaken(twitchUserId string, name string) (bool, error) {
+	var taken bool
+	err := db.pool.QueryRow(db.ctx, `select exists(select 1 from channel_commands where twitch_user_id=$1
+		and (command_name=$2 or $2=any(aliases)));`, twitchUserId, name).Scan(&taken)
+	return taken, err
+}
+
+// GetManagedChannels returns the joined channel of the user followed by the channels the user is a delegate of.
+func (db *ApiDb) GetManagedChannels(twitchUserId string) ([]ManagedChannel, error) {
+	rows, err := db.pool.Query(db.ctx, `select twitch_user_id, twitch_login, false from users_twitch where twitch_user_id=$1
+		union all
+		select u.twitch_user_id, u.twitch_login, true from channel_delegates d join users_twitch u on u.twitch_user_id=d.twitch_user_id
+		where d.delegate_user_id=$1
+		order by 3, 2;`, twitchUserId)
+	channels := make([]ManagedChannel, 0)
+	if err != nil {
+		return channels, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channel ManagedChannel
+		err = rows.Scan(&channel.TwitchUserId, &channel.TwitchLogin, &channel.Delegated)
+		if err != nil {
+			return channels, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+func (db *ApiDb) IsChannelDelegate(twitchUserId string, delegateUserId string) (bool, error) {
+	var delegate bool
+	err := db.pool.QueryRow(db.ctx, `select exists(select 1 from channel_delegates where twitch_user_id=$1 and delegate_user_id=$2);`,
+		twitchUserId, delegateUserId).Scan(&delegate)
+	return delegate, err
+}
@@ -17,6 +17,8 @@ import (
 var configuration = Configuration{}
 var ratelimiter ratelimit.SharedRateLimiter
 var apiDb *ApiDb
+var redisCache cache.Cache
+var invalidationBus *cache.InvalidationBus
 
 func main() {
 	metricsServer := http.NewServeMux()
@@ -34,7 +36,8 @@ func main() {
 		return
 	}
 
-	redisCache := cache.NewCache(configuration.CacheUrl)
+	redisCache = cache.NewCache(configuration.CacheUrl)
+	invalidationBus = cache.NewInvalidationBus(&redisCache, "api")
 	ratelimiter = ratelimit.NewSharedRateLimiter(&redisCache)
 
 	apiDb, err = NewApiDb(configuration.DbUri)
@@ -44,6 +47,17 @@ func main() {
 	}
 
 	http.Handle("/rank", httplog.WithLogging(withRateLimit(rankHandler())))
+	http.Handle("/history", httplog.WithLogging(withRateLimit(historyHandler())))
+	if configuration.TwitchClientId != "" {
+		http.Handle("/dashboard/auth/login", httplog.WithLogging(loginHandler()))
+		http.Handle("/dashboard/auth/callback", httplog.WithLogging(callbackHandler()))
+		http.Handle("/dashboard/auth/logout", httplog.WithLogging(logoutHandler()))
+		http.Handle("/dashboard/session", httplog.WithLogging(sessionHandler()))
+		http.Handle("/dashboard/channels", httplog.WithLogging(channelsHandler()))
+		http.Handle("/dashboard/channels/", httplog.WithLogging(channelHandler()))
+	} else {
+		log.WithField("event", "dashboard_disabled").Warn("No Twitch client id configured, the dashboard endpoints are disabled")
+	}
 	err = http.ListenAndServe(":8080", nil)
 	if err != nil {
 		log.WithField("event", "start_server").Fatal(err)
@@ -113,32 +127,67 @@ func rankHandler() http.Handler {
 		platform := url.QueryEscape(r.URL.Query().Get("platform"))
 		user := url.QueryEscape(r.URL.Query().Get("user"))
 
-		reqUrl := configuration.TrackerNetServiceUrl + "/rank?platform=" + platform + "&user=" + user
+		proxyTrackerNet(rw, "/rank?platform="+platform+"&user="+user, false)
+	}
+	return http.HandlerFunc(fn)
+}
 
-		req, err := http.NewRequest("GET", reqUrl, nil)
-		if err != nil {
-			rw.WriteHeader(500)
-			log.WithField("event", "new_request_trackernet").Error(err)
+func historyHandler() http.Handler {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("platform") == "" {
+			rw.WriteHeader(400)
+			_, _ = rw.Write([]byte("No platform specified"))
 			return
 		}
-		req.Header.Set("User-Agent", "yannismate-api/services/api")
-
-		res, err := httpClient.Do(req)
-		if err != nil {
-			rw.WriteHeader(500)
-			log.WithField("event", "do_request_trackernet").Error(err)
+		if r.URL.Query().Get("user") == "" {
+			rw.WriteHeader(400)
+			_, _ = rw.Write([]byte("No user specified"))
 			return
 		}
-		defer res.Body.Close()
 
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			rw.WriteHeader(500)
-			log.WithField("event", "read_body_trackernet").Error(err)
-			return
+		query := url.Values{}
+		for _, param := range []string{"platform", "user", "playlist", "since", "baseline"} {
+			if value := r.URL.Query().Get(param); value != "" {
+				query.Set(param, value)
+			}
 		}
 
-		_, _ = rw.Write(body)
+		proxyTrackerNet(rw, "/history?"+query.Encode(), true)
 	}
 	return http.HandlerFunc(fn)
 }
+
+// proxyTrackerNet passes the response body of the trackernet service on. Only with forwardStatus the upstream
+// status and content type are passed on as well, /rank always answered with 200.
+func proxyTrackerNet(rw http.ResponseWriter, path string, forwardStatus bool) {
+	req, err := http.NewRequest("GET", configuration.TrackerNetServiceUrl+path, nil)
+	if err != nil {
+		rw.WriteHeader(500)
+		log.WithField("event", "new_request_trackernet").Error(err)
+		return
+	}
+	req.Header.Set("User-Agent", "yannismate-api/services/api")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		rw.WriteHeader(500)
+		log.WithField("event", "do_request_trackernet").Error(err)
+		return
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		rw.WriteHeader(500)
+		log.WithField("event", "read_body_trackernet").Error(err)
+		return
+	}
+
+	if forwardStatus {
+		if contentType := res.Header.Get("Content-Type"); contentType != "" {
+			rw.Header().Set("Content-Type", contentType)
+		}
+		rw.WriteHeader(res.StatusCode)
+	}
+	_, _ = rw.Write(body)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
+	"sync"
+	"time"
+)
+
+type inFlightCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// callGroup collapses concurrent calls with the same key into a single execution.
+type callGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inFlightCall
+}
+
+func (g *callGroup) Do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*inFlightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.data, call.err
+	}
+	// waiters see this error if fn panics instead of returning
+	call := &inFlightCall{done: make(chan struct{}), err: errors.New("coalesced call did not return")}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.data, call.err = fn()
+	return call.data, call.err
+}
+
+var refreshGroup callGroup
+
+const lockPollInterval = time.Millisecond * 250
+const defaultLockTtl = time.Second * 60
+
+// refreshRanksCoalesced makes sure only one refresh per player runs at a time, within this process via
+// refreshGroup and across replicas via a redis lock. Callers that lose the race wait for the winner's result.
+func refreshRanksCoalesced(platform string, user string, priority webscraper.Priority) ([]byte, error) {
+	key := platform + ":" + user
+	return refreshGroup.Do(key, func() ([]byte, error) {
+		waitStart := time.Now()
+		lockTtl := time.Second * time.Duration(configuration.Cache.LockSeconds)
+		if lockTtl <= 0 {
+			lockTtl = defaultLockTtl
+		}
+
+		for {
+			token, err := redisCache.TryLock("rank:"+key, lockTtl)
+			if err != nil {
+				log.WithField("event", "cache_lock").Warn(err)
+				return refreshRanks(platform, user, priority)
+			}
+			if token != "" {
+				defer func() {
+					err := redisCache.Unlock("rank:"+key, token)
+					if err != nil {
+						log.WithField("event", "cache_unlock").Warn(err)
+					}
+				}()
+				return refreshRanks(platform, user, priority)
+			}
+
+			// another replica is already scraping this player
+			for time.Since(waitStart) < lockTtl {
+				time.Sleep(lockPollInterval)
+
+				cached, err := getCachedRanks(platform, user)
+				if err == nil && cached.FetchedAt >= waitStart.Unix() {
+					return cached.Data, nil
+				}
+
+				locked, err := redisCache.IsLocked("rank:" + key)
+				if err != nil || !locked {
+					break
+				}
+			}
+
+			if time.Since(waitStart) >= lockTtl {
+				return refreshRanks(platform, user, priority)
+			}
+		}
+	})
+}
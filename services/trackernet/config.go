@@ -4,13 +4,23 @@ type Configuration struct {
 	TrackerNet TnConfig
 	Cache      CacheConfig
 	ScraperUrl string
+	DbUri      string
+	Providers  []string
+	Fixture    FixtureConfig
 }
 
 type TnConfig struct {
-	BaseUrl string
+	BaseUrl    string
+	ScrapeMode string
 }
 
 type CacheConfig struct {
-	RedisUrl   string
-	TtlSeconds int
+	RedisUrl       string
+	SoftTtlSeconds int
+	TtlSeconds     int
+	LockSeconds    int
+}
+
+type FixtureConfig struct {
+	Dir string
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"time"
+)
+
+type TnDb struct {
+	ctx  context.Context
+	pool *pgxpool.Pool
+}
+
+func NewTnDb(uri string) (*TnDb, error) {
+	ctx := context.Background()
+
+	dbPool, err := pgxpool.Connect(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TnDb{ctx: ctx, pool: dbPool}, nil
+}
+
+// InsertRankSnapshot stores all rankings of a player, skipping playlists that did not change since the last snapshot.
+func (db *TnDb) InsertRankSnapshot(platform string, player string, rankings []trackernet.Ranking) error {
+	batch := &pgx.Batch{}
+	for _, ranking := range rankings {
+		batch.Queue(`insert into rank_history (platform, player, playlist, mmr, rank, division)
+			select $1, $2, $3, $4, $5, $6 where not exists (
+				select 1 from (select mmr, rank, division from rank_history where platform=$1 and player=$2 and playlist=$3
+					order by recorded_at desc limit 1) latest
+				where latest.mmr=$4 and latest.rank=$5 and latest.division=$6);`,
+			platform, player, string(ranking.Playlist), ranking.Mmr, ranking.Rank, ranking.Division)
+	}
+
+	res := db.pool.SendBatch(db.ctx, batch)
+	defer res.Close()
+
+	for range rankings {
+		_, err := res.Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRankHistoryBaseline returns the latest entry per playlist recorded before since.
+func (db *TnDb) GetRankHistoryBaseline(platform string, player string, playlist string, since time.Time) ([]trackernet.HistoryEntry, error) {
+	rows, err := db.pool.Query(db.ctx, `select distinct on (playlist) playlist, mmr, rank, division, recorded_at from rank_history
+		where platform=$1 and player=$2 and ($3 = '' or playlist=$3) and recorded_at < $4
+		order by playlist, recorded_at desc;`, platform, player, playlist, since)
+	if err != nil {
+		return make([]trackernet.HistoryEntry, 0), err
+	}
+	return toHistoryEntries(rows)
+}
+
+func (db *TnDb) GetRankHistory(platform string, player string, playlist string, since time.Time, limit int) ([]trackernet.HistoryEntry, error) {
+	rows, err := db.pool.Query(db.ctx, `select playlist, mmr, rank, division, recorded_at from rank_history
+		where platform=$1 and player=$2 and ($3 = '' or playlist=$3) and recorded_at >= $4
+		order by recorded_at asc limit $5;`, platform, player, playlist, since, limit)
+	if err != nil {
+		return make([]trackernet.HistoryEntry, 0), err
+	}
+	return toHistoryEntries(rows)
+}
+
+func toHistoryEntries(rows pgx.Rows) ([]trackernet.HistoryEntry, error) {
+	defer rows.Close()
+	entries := make([]trackernet.HistoryEntry, 0)
+
+	for rows.Next() {
+		var entry trackernet.HistoryEntry
+		var entryPlaylist string
+		err := rows.Scan(&entryPlaylist, &entry.Mmr, &entry.Rank, &entry.Division, &entry.RecordedAt)
+		if err != nil {
+			return entries, err
+		}
+		entry.Playlist = trackernet.Playlist(entryPlaylist)
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const historyLimit = 5000
+const defaultHistoryDuration = time.Hour * 24 * 30
+
+var validPlaylists = map[string]bool{
+	trackernet.Unranked: true, trackernet.Ranked1v1: true, trackernet.Ranked2v2: true, trackernet.Ranked3v3: true,
+	trackernet.Hoops: true, trackernet.Rumble: true, trackernet.Dropshot: true, trackernet.Snowday: true,
+	trackernet.Tournaments: true,
+}
+
+func historyHandler() http.Handler {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		if tnDb == nil {
+			rw.WriteHeader(501)
+			return
+		}
+
+		platform, ok := platforms[strings.ToLower(r.URL.Query().Get("platform"))]
+		if !ok {
+			rw.WriteHeader(400)
+			return
+		}
+
+		user := r.URL.Query().Get("user")
+		if user == "" {
+			rw.WriteHeader(400)
+			return
+		}
+
+		playlist := strings.ToLower(r.URL.Query().Get("playlist"))
+		if playlist != "" && !validPlaylists[playlist] {
+			rw.WriteHeader(400)
+			return
+		}
+
+		since, err := parseSince(r.URL.Query().Get("since"))
+		if err != nil {
+			rw.WriteHeader(400)
+			return
+		}
+
+		entries, err := tnDb.GetRankHistory(platform, strings.ToLower(user), playlist, since, historyLimit)
+		if err != nil {
+			log.WithField("event", "history_get").Error(err)
+			rw.WriteHeader(500)
+			return
+		}
+
+		// baseline=1 prepends the last known state of each playlist before since
+		if r.URL.Query().Get("baseline") == "1" {
+			baseline, err := tnDb.GetRankHistoryBaseline(platform, strings.ToLower(user), playlist, since)
+			if err != nil {
+				log.WithField("event", "history_get_baseline").Error(err)
+				rw.WriteHeader(500)
+				return
+			}
+			entries = append(baseline, entries...)
+		}
+
+		jData, err := json.Marshal(trackernet.GetHistoryResponse{Entries: entries})
+		if err != nil {
+			log.WithField("event", "json_encode").Error(err)
+			rw.WriteHeader(500)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(200)
+		_, err = rw.Write(jData)
+		if err != nil {
+			log.WithField("event", "write_response").Error(err)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// parseSince accepts unix seconds or RFC 3339 timestamps and defaults to the last 30 days.
+func parseSince(since string) (time.Time, error) {
+	if since == "" {
+		return time.Now().Add(-defaultHistoryDuration), nil
+	}
+	if unix, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, since)
+}
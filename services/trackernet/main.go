@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	metrics "github.com/slok/go-http-metrics/metrics/prometheus"
@@ -11,13 +12,16 @@ import (
 	"github.com/yannismate/yannismate-api/libs/cache"
 	"github.com/yannismate/yannismate-api/libs/httplog"
 	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var configuration = Configuration{}
 var redisCache cache.Cache
+var tnDb *TnDb
 
 func main() {
 	err := gonfig.GetConf("config.json", &configuration)
@@ -28,6 +32,21 @@ func main() {
 
 	redisCache = cache.NewCache(configuration.Cache.RedisUrl)
 
+	if configuration.DbUri != "" {
+		tnDb, err = NewTnDb(configuration.DbUri)
+		if err != nil {
+			// rank lookups do not depend on the database, keep serving them without history
+			log.WithField("event", "connect_db").Error(err, ", running without rank history")
+			tnDb = nil
+		}
+	}
+
+	rankProviders, err = NewRankProviders(configuration.Providers)
+	if err != nil {
+		log.WithField("event", "load_providers").Fatal(err)
+		return
+	}
+
 	mdlw := metricsMw.New(metricsMw.Config{
 		Recorder: metrics.NewRecorder(metrics.Config{
 			DurationBuckets: []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600},
@@ -36,6 +55,7 @@ func main() {
 
 	http.Handle("/metrics", promhttp.Handler())
 	http.Handle("/rank", metricsMwStd.Handler("rank", mdlw, httplog.WithLogging(rankHandler())))
+	http.Handle("/history", metricsMwStd.Handler("history", mdlw, httplog.WithLogging(historyHandler())))
 	err = http.ListenAndServe(":8080", nil)
 	if err != nil {
 		log.WithField("event", "start_server").Fatal(err)
@@ -44,6 +64,11 @@ func main() {
 
 var platforms = map[string]string{trackernet.Steam: "steam", trackernet.Epic: "epic", trackernet.PS: "psn", trackernet.Xbox: "xbl"}
 
+type CachedRanks struct {
+	FetchedAt int64           `json:"fetchedAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
 func rankHandler() http.Handler {
 	fn := func(rw http.ResponseWriter, r *http.Request) {
 
@@ -59,39 +84,41 @@ func rankHandler() http.Handler {
 			return
 		}
 
-		cacheRes, err := redisCache.Get(platform + ":" + user)
+		cached, err := getCachedRanks(platform, user)
 		if err == nil {
+			age := time.Now().Unix() - cached.FetchedAt
+			cacheState := "hit"
+			if configuration.Cache.SoftTtlSeconds > 0 && age >= int64(configuration.Cache.SoftTtlSeconds) {
+				cacheState = "stale"
+				go refreshInBackground(platform, user)
+			}
+
 			rw.Header().Set("Content-Type", "application/json")
+			rw.Header().Set("X-Cache", cacheState)
+			rw.Header().Set("Age", strconv.FormatInt(age, 10))
 			rw.WriteHeader(200)
-			_, _ = rw.Write([]byte(cacheRes))
+			_, _ = rw.Write(cached.Data)
 			return
 		}
 
-		rankRes, err := GetRanks(platform, user)
-		if err != nil {
-			if _, ok := err.(*TggError); ok {
-				rw.WriteHeader(404)
-				return
-			} else {
-				log.WithField("event", "get_ranks").Warn(err)
-				rw.WriteHeader(500)
-				return
-			}
+		priority := webscraper.Priority(r.URL.Query().Get("priority"))
+		if priority != webscraper.PriorityInteractive {
+			priority = webscraper.PriorityDefault
 		}
 
-		jData, err := json.Marshal(rankRes)
+		jData, err := refreshRanksCoalesced(platform, user, priority)
 		if err != nil {
-			log.WithField("event", "json_encode").Error(err)
-			rw.WriteHeader(500)
+			status := statusForError(err)
+			if status != 404 {
+				log.WithField("event", "get_ranks").WithField("status", status).Warn(err)
+			}
+			rw.WriteHeader(status)
 			return
 		}
 
-		err = redisCache.SetWithTtl(platform+":"+user, string(jData), time.Second*time.Duration(configuration.Cache.TtlSeconds))
-		if err != nil {
-			log.WithField("event", "cache_set").Error(err)
-		}
-
 		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("X-Cache", "miss")
+		rw.Header().Set("Age", "0")
 		rw.WriteHeader(200)
 		_, err = rw.Write(jData)
 		if err != nil {
@@ -101,3 +128,62 @@ func rankHandler() http.Handler {
 	}
 	return http.HandlerFunc(fn)
 }
+
+func getCachedRanks(platform string, user string) (*CachedRanks, error) {
+	cacheRes, err := redisCache.Get(platform + ":" + user)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := CachedRanks{}
+	err = json.Unmarshal([]byte(cacheRes), &cached)
+	if err != nil {
+		return nil, err
+	}
+	// entries written before the fetchedAt wrapper unmarshal without error but carry no data
+	if cached.Data == nil || cached.FetchedAt == 0 {
+		return nil, errors.New("outdated cache entry")
+	}
+	return &cached, nil
+}
+
+// refreshRanks fetches fresh ranks from the providers and stores them in the cache until the hard TTL expires.
+func refreshRanks(platform string, user string, priority webscraper.Priority) ([]byte, error) {
+	rankRes, err := GetRanks(platform, user, priority)
+	if err != nil {
+		return nil, err
+	}
+
+	if tnDb != nil {
+		err = tnDb.InsertRankSnapshot(platform, strings.ToLower(user), rankRes.Rankings)
+		if err != nil {
+			log.WithField("event", "history_insert").Error(err)
+		}
+	}
+
+	jData, err := json.Marshal(rankRes)
+	if err != nil {
+		log.WithField("event", "json_encode").Error(err)
+		return nil, err
+	}
+
+	toCache, err := json.Marshal(CachedRanks{FetchedAt: time.Now().Unix(), Data: jData})
+	if err != nil {
+		log.WithField("event", "json_encode").Error(err)
+		return jData, nil
+	}
+
+	err = redisCache.SetWithTtl(platform+":"+user, string(toCache), time.Second*time.Duration(configuration.Cache.TtlSeconds))
+	if err != nil {
+		log.WithField("event", "cache_set").Error(err)
+	}
+
+	return jData, nil
+}
+
+func refreshInBackground(platform string, user string) {
+	_, err := refreshRanksCoalesced(platform, user, webscraper.PriorityBackground)
+	if err != nil {
+		log.WithField("event", "background_refresh").Warn(err)
+	}
+}
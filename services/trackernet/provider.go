@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type RankProvider interface {
+	Name() string
+	GetRanks(platform string, user string, priority webscraper.Priority) (*trackernet.GetRankResponse, error)
+}
+
+type PlayerNotFoundError struct {
+	Provider string
+}
+
+func (p PlayerNotFoundError) Error() string {
+	return "player not found by provider " + p.Provider
+}
+
+type ScraperUnavailableError struct {
+	StatusCode int
+}
+
+func (s ScraperUnavailableError) Error() string {
+	return "webscraper responded with status " + strconv.Itoa(s.StatusCode)
+}
+
+type UpstreamBlockedError struct {
+	StatusCode int
+	FinalUrl   string
+}
+
+func (u UpstreamBlockedError) Error() string {
+	return "upstream blocked the request with status " + strconv.Itoa(u.StatusCode) + " at " + u.FinalUrl
+}
+
+type UpstreamStatusError struct {
+	StatusCode int
+}
+
+func (u UpstreamStatusError) Error() string {
+	return "upstream responded with status " + strconv.Itoa(u.StatusCode)
+}
+
+type InvalidResponseError struct {
+	Reason string
+}
+
+func (i InvalidResponseError) Error() string {
+	return i.Reason
+}
+
+type NoProvidersError struct{}
+
+func (n NoProvidersError) Error() string {
+	return "no rank providers configured"
+}
+
+var rankProviders []RankProvider
+
+func NewRankProviders(names []string) ([]RankProvider, error) {
+	if len(names) == 0 {
+		names = []string{"trackergg"}
+	}
+
+	providers := make([]RankProvider, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "trackergg":
+			providers = append(providers, &TggProvider{
+				BaseUrl:    configuration.TrackerNet.BaseUrl,
+				ScraperUrl: configuration.ScraperUrl,
+				ScrapeMode: configuration.TrackerNet.ScrapeMode,
+			})
+		case "fixture":
+			providers = append(providers, &FixtureProvider{Dir: configuration.Fixture.Dir})
+		default:
+			return nil, fmt.Errorf("unknown rank provider %q", name)
+		}
+	}
+	return providers, nil
+}
+
+// GetRanks asks every configured provider in order and returns the first successful response.
+// A player is only reported as not found if no provider returned a result.
+func GetRanks(platform string, user string, priority webscraper.Priority) (*trackernet.GetRankResponse, error) {
+	var lastErr error = &NoProvidersError{}
+	var notFoundErr error
+
+	for _, provider := range rankProviders {
+		res, err := provider.GetRanks(platform, user, priority)
+		if err == nil {
+			return res, nil
+		}
+
+		var pnf *PlayerNotFoundError
+		if errors.As(err, &pnf) {
+			notFoundErr = err
+		} else {
+			lastErr = fmt.Errorf("%s: %w", provider.Name(), err)
+		}
+	}
+
+	if notFoundErr != nil {
+		return nil, notFoundErr
+	}
+	return nil, lastErr
+}
+
+// FixtureProvider serves responses from <Dir>/<platform>/<user>.json, meant for local testing.
+type FixtureProvider struct {
+	Dir string
+}
+
+func (f *FixtureProvider) Name() string {
+	return "fixture"
+}
+
+func (f *FixtureProvider) GetRanks(platform string, user string, _ webscraper.Priority) (*trackernet.GetRankResponse, error) {
+	path := filepath.Join(f.Dir, filepath.Base(platform), filepath.Base(strings.ToLower(user))+".json")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &PlayerNotFoundError{Provider: f.Name()}
+		}
+		return nil, err
+	}
+
+	res := trackernet.GetRankResponse{}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// statusForError maps provider errors to the status code returned by the rank endpoint.
+func statusForError(err error) int {
+	var pnf *PlayerNotFoundError
+	var sue *ScraperUnavailableError
+	var ube *UpstreamBlockedError
+	var use *UpstreamStatusError
+	var ire *InvalidResponseError
+
+	switch {
+	case errors.As(err, &pnf):
+		return 404
+	case errors.As(err, &sue), errors.As(err, &ube):
+		return 503
+	case errors.As(err, &use), errors.As(err, &ire):
+		return 502
+	}
+	return 500
+}
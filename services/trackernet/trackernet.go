@@ -15,10 +15,24 @@ var httpClient = http.Client{
 	Timeout: time.Second * 10,
 }
 
-func GetRanks(platform string, user string) (*trackernet.GetRankResponse, error) {
+type TggProvider struct {
+	BaseUrl    string
+	ScraperUrl string
+	ScrapeMode string
+}
+
+func (t *TggProvider) Name() string {
+	return "trackergg"
+}
 
-	requestUrl := configuration.TrackerNet.BaseUrl + "/" + platform + "/" + strings.Replace(url.QueryEscape(user), "+", "%20", -1)
-	req, err := http.NewRequest("GET", configuration.ScraperUrl+"?url="+url.QueryEscape(requestUrl), nil)
+func (t *TggProvider) GetRanks(platform string, user string, priority webscraper.Priority) (*trackernet.GetRankResponse, error) {
+
+	requestUrl := t.BaseUrl + "/" + platform + "/" + strings.Replace(url.QueryEscape(user), "+", "%20", -1)
+	scraperUrl := t.ScraperUrl + "?url=" + url.QueryEscape(requestUrl) + "&priority=" + url.QueryEscape(string(priority))
+	if t.ScrapeMode != "" {
+		scraperUrl += "&mode=" + url.QueryEscape(t.ScrapeMode)
+	}
+	req, err := http.NewRequest("GET", scraperUrl, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -30,6 +44,10 @@ func GetRanks(platform string, user string) (*trackernet.GetRankResponse, error)
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode != 200 {
+		return nil, &ScraperUnavailableError{StatusCode: res.StatusCode}
+	}
+
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
@@ -38,18 +56,26 @@ func GetRanks(platform string, user string) (*trackernet.GetRankResponse, error)
 	scraperRes := webscraper.GetScrapeResponse{}
 	err = json.Unmarshal(body, &scraperRes)
 	if err != nil {
-		return nil, err
+		return nil, &InvalidResponseError{Reason: "invalid scraper response: " + err.Error()}
 	}
 
 	tggRes := TggResponse{}
-
 	err = json.Unmarshal([]byte(scraperRes.Content), &tggRes)
 	if err != nil {
-		return nil, err
+		if isBlockedResponse(&scraperRes) {
+			return nil, &UpstreamBlockedError{StatusCode: scraperRes.StatusCode, FinalUrl: scraperRes.FinalUrl}
+		}
+		if scraperRes.StatusCode == 404 {
+			return nil, &PlayerNotFoundError{Provider: t.Name()}
+		}
+		if scraperRes.StatusCode >= 400 {
+			return nil, &UpstreamStatusError{StatusCode: scraperRes.StatusCode}
+		}
+		return nil, &InvalidResponseError{Reason: "unexpected " + scraperRes.ContentType + " content extracted from " + string(scraperRes.Extraction) + ": " + err.Error()}
 	}
 
 	if len(tggRes.Errors) > 0 {
-		return nil, &TggError{}
+		return nil, &PlayerNotFoundError{Provider: t.Name()}
 	}
 
 	rankings := make([]trackernet.Ranking, 0)
@@ -100,12 +126,6 @@ type TggStatsValue struct {
 	Value int `json:"value"`
 }
 
-type TggError struct{}
-
-func (t TggError) Error() string {
-	return "tracker.gg API returned error object"
-}
-
 var playlists = map[string]trackernet.Playlist{"Un-Ranked": trackernet.Unranked, "Ranked Duel 1v1": trackernet.Ranked1v1,
 	"Ranked Doubles 2v2": trackernet.Ranked2v2, "Ranked Standard 3v3": trackernet.Ranked3v3, "Hoops": trackernet.Hoops,
 	"Rumble": trackernet.Rumble, "Dropshot": trackernet.Dropshot, "Snowday": trackernet.Snowday, "Tournament Matches": trackernet.Tournaments}
@@ -124,3 +144,11 @@ func (seg *TggSegment) toRanking() *trackernet.Ranking {
 		Division: seg.Stats.Division.Value,
 	}
 }
+
+// isBlockedResponse detects bot protection pages returned instead of the tracker.gg api response.
+func isBlockedResponse(res *webscraper.GetScrapeResponse) bool {
+	if res.StatusCode == 403 || res.StatusCode == 429 || res.StatusCode == 503 {
+		return true
+	}
+	return res.Extraction == webscraper.ExtractionPageSource && strings.Contains(res.Content, "challenge-platform")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/rankformat"
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rankWatcherPeriod          = time.Second * 30
+	minRankAnnounceInterval    = 60
+	maxRankAnnounceInterval    = 3600
+	rankSnapshotTtl            = time.Hour * 24
+	maxRankAnnounceFormatChars = 500
+)
+
+// RankSnapshot is the last seen rank of every playlist of the channel's player.
+type RankSnapshot struct {
+	Platform string                            `json:"p"`
+	Username string                            `json:"u"`
+	Ranks    map[trackernet.Playlist]rankState `json:"r"`
+}
+
+type rankState struct {
+	Rank     int `json:"r"`
+	Division int `json:"d"`
+	Mmr      int `json:"m"`
+}
+
+func rankSnapshotKey(twitchUserId string) string {
+	return channelCacheKey(twitchUserId) + ":snapshot"
+}
+
+func rankPollKey(twitchUserId string) string {
+	return channelCacheKey(twitchUserId) + ":rankpoll"
+}
+
+func registerRankWatcher() {
+	onStreamOffline(func(event StreamEvent) {
+		// the next stream starts with a fresh snapshot instead of announcing everything that changed offline
+		redisCache.Delete(rankSnapshotKey(event.BroadcasterUserId))
+	})
+	go runRankWatcher()
+}
+
+// runRankWatcher polls the players of live channels with rank announcements and posts tier or division changes.
+func runRankWatcher() {
+	ticker := time.NewTicker(rankWatcherPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		users, err := botDb.GetRankAnnounceUsers()
+		if err != nil {
+			log.WithField("event", "rank_watcher").Error(err)
+			continue
+		}
+		ids := make([]string, 0, len(users))
+		for _, user := range users {
+			ids = append(ids, user.TwitchUserId)
+		}
+		live := liveChannels(ids)
+		for _, user := range users {
+			if user.RlPlatform == "" || user.RlUsername == "" || !live[user.TwitchUserId] {
+				continue
+			}
+			// the poll key makes sure only one replica polls a channel per interval
+			due, err := redisCache.SetIfAbsent(rankPollKey(user.TwitchUserId), "1", time.Second*time.Duration(user.RankAnnounceInterval))
+			if err != nil {
+				log.WithField("event", "rank_watcher").Error(err)
+				continue
+			}
+			if due {
+				go pollRankChanges(user)
+			}
+		}
+	}
+}
+
+func pollRankChanges(user *BotUser) {
+	res, err := requestRankWithPriority(user.RlPlatform, user.RlUsername, webscraper.PriorityDefault)
+	if err != nil {
+		log.WithField("event", "rank_watcher_poll").WithField("channel", user.TwitchLogin).Warn(err)
+		return
+	}
+
+	current := RankSnapshot{Platform: user.RlPlatform, Username: user.RlUsername, Ranks: make(map[trackernet.Playlist]rankState)}
+	for _, ranking := range res.Rankings {
+		if ranking.Playlist == trackernet.Unranked || ranking.Rank <= 0 {
+			continue
+		}
+		current.Ranks[ranking.Playlist] = rankState{Rank: ranking.Rank, Division: ranking.Division, Mmr: ranking.Mmr}
+	}
+
+	previous := RankSnapshot{}
+	cachedStr, err := redisCache.Get(rankSnapshotKey(user.TwitchUserId))
+	hasPrevious := err == nil && json.Unmarshal([]byte(cachedStr), &previous) == nil &&
+		previous.Platform == current.Platform && strings.EqualFold(previous.Username, current.Username)
+
+	toCacheStr, _ := json.Marshal(current)
+	err = redisCache.SetWithTtl(rankSnapshotKey(user.TwitchUserId), string(toCacheStr), rankSnapshotTtl)
+	if err != nil {
+		log.WithField("event", "rank_snapshot_set").Error(err)
+	}
+	if !hasPrevious {
+		return
+	}
+
+	for _, ranking := range res.Rankings {
+		now, ok := current.Ranks[ranking.Playlist]
+		if !ok {
+			continue
+		}
+		before, ok := previous.Ranks[ranking.Playlist]
+		if !ok || (before.Rank == now.Rank && before.Division == now.Division) {
+			continue
+		}
+		change := rankformat.RankChange{
+			Playlist: ranking.Playlist,
+			Before:   trackernet.Ranking{Playlist: ranking.Playlist, Rank: before.Rank, Division: before.Division, Mmr: before.Mmr},
+			Now:      ranking,
+			Up:       now.Rank > before.Rank || (now.Rank == before.Rank && now.Division > before.Division),
+		}
+		outbound.Say(user.TwitchLogin, formatRankAnnouncement(user, res, &change))
+		metricRankAnnouncements.Inc()
+	}
+}
+
+// formatRankAnnouncement renders the channel's announcement format or the translated default message.
+func formatRankAnnouncement(user *BotUser, res *trackernet.GetRankResponse, change *rankformat.RankChange) string {
+	language := user.Language
+	if !isSupportedLanguage(language) {
+		language = defaultLanguage
+	}
+
+	if user.RankAnnounceFormat != "" {
+		tmpl, err := rankformat.ParseAnnouncement(user.RankAnnounceFormat)
+		if err == nil {
+			var baselines *rankformat.Baselines
+			if tmpl.UsesDeltas {
+				baselines = loadMmrBaselines(user.TwitchUserId, user.RlPlatform, user.RlUsername)
+			}
+			return tmpl.RenderAnnouncement(res, baselines, change, rankNamer(language))
+		}
+		log.WithField("event", "rank_announcement_format").WithField("channel", user.TwitchLogin).Warn(err)
+	}
+
+	key := "rank_down_announcement"
+	if change.Up {
+		key = "rank_up_announcement"
+	}
+	return translate(language, key, rankToStr(change.Now.Rank, "l", language), strconv.Itoa(change.Now.Division+1), rankformat.PlaylistNames[change.Playlist])
+}
+
+func setRankAnnounceIntervalCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "setrankannounceinterval_command").WithField("channel", message.Channel).Info("Executing setrankannounceinterval command")
+	newInterval, err := strconv.ParseInt(args[0], 10, 0)
+	if err != nil || newInterval < minRankAnnounceInterval || newInterval > maxRankAnnounceInterval {
+		reply(message, "rank_announce_interval_invalid", strconv.Itoa(minRankAnnounceInterval), strconv.Itoa(maxRankAnnounceInterval))
+		return
+	}
+
+	twitchUserId := channelOwnerId(message)
+
+	wasChanged, err := botDb.UpdateRankAnnounceIntervalByTwitchUserId(twitchUserId, int(newInterval))
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "setrankannounceinterval_command_db_update").Error(err)
+		return
+	}
+	if !wasChanged {
+		reply(message, "not_joined")
+		return
+	}
+	redisCache.Delete(rankPollKey(twitchUserId))
+	reply(message, "rank_announce_interval_set", strconv.FormatInt(newInterval, 10))
+}
+
+func setRankAnnounceFormatCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "setrankannounceformat_command").WithField("channel", message.Channel).Info("Executing setrankannounceformat command")
+	newFormat := args[0]
+	if strings.ToLower(newFormat) == "reset" {
+		newFormat = ""
+	}
+	if len(newFormat) > maxRankAnnounceFormatChars {
+		reply(message, "rank_announce_format_too_long", strconv.Itoa(maxRankAnnounceFormatChars))
+		return
+	}
+	if newFormat != "" {
+		if _, err := rankformat.ParseAnnouncement(newFormat); err != nil {
+			reply(message, "invalid_format", err.Error())
+			return
+		}
+	}
+
+	twitchUserId := channelOwnerId(message)
+
+	wasChanged, err := botDb.UpdateRankAnnounceFormatByTwitchUserId(twitchUserId, newFormat)
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "setrankannounceformat_command_db_update").Error(err)
+		return
+	}
+	if !wasChanged {
+		reply(message, "not_joined")
+		return
+	}
+	if newFormat == "" {
+		reply(message, "rank_announce_format_reset")
+	} else {
+		reply(message, "rank_announce_format_set")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/cache"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedChannel maps every command name and alias of a channel to the command it triggers.
+type CachedChannel struct {
+	TwitchUserId   string            `json:"id"`
+	TwitchLogin    string            `json:"lg"`
+	Triggers       map[string]string `json:"trg"`
+	LookupEnabled  bool              `json:"lke"`
+	LookupCooldown int64             `json:"lkcd"`
+	UserCooldown   int64             `json:"ucd"`
+	CdExempt       bool              `json:"cdx"`
+	CdWhisper      bool              `json:"cdw"`
+	Prefix         string            `json:"pfx"`
+	MentionOnly    bool              `json:"mo"`
+	Language       string            `json:"lang"`
+	LiveOnly       bool              `json:"lo"`
+}
+
+// CachedCommand is a single rank command with the channel settings already applied as fallback.
+type CachedCommand struct {
+	Name            string `json:"name"`
+	CooldownSeconds int64  `json:"cd"`
+	RlPlatform      string `json:"rlp"`
+	RlUsername      string `json:"rlu"`
+	MessageFormat   string `json:"fmt"`
+}
+
+const channelCacheTtl = time.Hour
+
+// Channels are also kept in memory as almost every chat message needs them. Changes are announced on the
+// invalidation bus, the short ttl only bounds the staleness if an event gets lost.
+const localChannelTtl = time.Minute
+
+type localChannel struct {
+	channel *CachedChannel
+	expires time.Time
+}
+
+var localChannelsMu sync.RWMutex
+var localChannels = make(map[string]localChannel)
+
+func getLocalChannel(twitchUserId string) (*CachedChannel, bool) {
+	localChannelsMu.RLock()
+	defer localChannelsMu.RUnlock()
+	local, ok := localChannels[twitchUserId]
+	if !ok || time.Now().After(local.expires) {
+		return nil, false
+	}
+	return local.channel, true
+}
+
+func setLocalChannel(channel *CachedChannel) {
+	localChannelsMu.Lock()
+	defer localChannelsMu.Unlock()
+	localChannels[channel.TwitchUserId] = localChannel{channel: channel, expires: time.Now().Add(localChannelTtl)}
+}
+
+func dropLocalChannel(twitchUserId string) {
+	localChannelsMu.Lock()
+	defer localChannelsMu.Unlock()
+	delete(localChannels, twitchUserId)
+}
+
+// Channels are cached by user id as the login changes when a streamer renames.
+func channelCacheKey(twitchUserId string) string {
+	return cache.TwitchChannelKey(twitchUserId)
+}
+
+func commandCacheKey(twitchUserId string, name string) string {
+	return cache.TwitchCommandKey(twitchUserId, name)
+}
+
+func lookupCooldownKey(twitchUserId string) string {
+	return channelCacheKey(twitchUserId) + ":lookup"
+}
+
+// getCachedChannel returns the command index of a channel, loading it from the database on a cache miss.
+func getCachedChannel(twitchUserId string) (*CachedChannel, bool, error) {
+	if channel, ok := getLocalChannel(twitchUserId); ok {
+		return channel, true, nil
+	}
+
+	cachedStr, err := redisCache.Get(channelCacheKey(twitchUserId))
+	if err == nil {
+		cachedObj := CachedChannel{}
+		err = json.Unmarshal([]byte(cachedStr), &cachedObj)
+		if err == nil && cachedObj.Triggers != nil && cachedObj.Prefix != "" {
+			setLocalChannel(&cachedObj)
+			return &cachedObj, true, nil
+		}
+	}
+
+	channel, err := loadChannelFromDb(twitchUserId)
+	if err != nil {
+		return nil, false, err
+	}
+	setLocalChannel(channel)
+	return channel, false, nil
+}
+
+func getCachedCommand(twitchUserId string, name string) (*CachedCommand, error) {
+	cachedStr, err := redisCache.Get(commandCacheKey(twitchUserId, name))
+	if err != nil {
+		_, err = loadChannelFromDb(twitchUserId)
+		if err != nil {
+			return nil, err
+		}
+		cachedStr, err = redisCache.Get(commandCacheKey(twitchUserId, name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cachedObj := CachedCommand{}
+	err = json.Unmarshal([]byte(cachedStr), &cachedObj)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedObj, nil
+}
+
+func saveCachedCommand(twitchUserId string, cmd *CachedCommand) {
+	toCacheStr, _ := json.Marshal(cmd)
+	err := redisCache.SetWithTtl(commandCacheKey(twitchUserId, cmd.Name), string(toCacheStr), channelCacheTtl)
+	if err != nil {
+		log.WithField("event", "command_cache_set").Error(err)
+	}
+}
+
+func loadChannelFromDb(twitchUserId string) (*CachedChannel, error) {
+	dbUser, err := botDb.GetBotUserByTwitchUserId(twitchUserId)
+	if err != nil {
+		return nil, err
+	}
+	commands, err := botDb.GetChannelCommands(dbUser.TwitchUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := CachedChannel{
+		TwitchUserId:   dbUser.TwitchUserId,
+		TwitchLogin:    dbUser.TwitchLogin,
+		Triggers:       map[string]string{strings.ToLower(dbUser.TwitchCommandName): dbUser.TwitchCommandName},
+		LookupEnabled:  dbUser.LookupEnabled,
+		LookupCooldown: int64(dbUser.LookupCooldown),
+		UserCooldown:   int64(dbUser.UserCooldown),
+		CdExempt:       dbUser.CooldownExempt,
+		CdWhisper:      dbUser.CooldownWhisper,
+		Prefix:         dbUser.CommandPrefix,
+		MentionOnly:    dbUser.MentionOnly,
+		Language:       dbUser.Language,
+		LiveOnly:       dbUser.LiveOnly,
+	}
+	saveCachedCommand(twitchUserId, &CachedCommand{
+		Name:            dbUser.TwitchCommandName,
+		CooldownSeconds: int64(dbUser.TwitchCommandCooldown),
+		RlPlatform:      dbUser.RlPlatform,
+		RlUsername:      dbUser.RlUsername,
+		MessageFormat:   dbUser.RlMessageFormat,
+	})
+
+	for _, cmd := range commands {
+		for _, trigger := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if _, exists := channel.Triggers[trigger]; !exists {
+				channel.Triggers[trigger] = cmd.Name
+			}
+		}
+		saveCachedCommand(twitchUserId, resolveChannelCommand(dbUser, &cmd))
+	}
+
+	toCacheStr, _ := json.Marshal(channel)
+	err = redisCache.SetWithTtl(channelCacheKey(twitchUserId), string(toCacheStr), channelCacheTtl)
+	if err != nil {
+		log.WithField("event", "channel_cache_set").Error(err)
+	}
+	return &channel, nil
+}
+
+func resolveChannelCommand(dbUser *BotUser, cmd *ChannelCommand) *CachedCommand {
+	resolved := CachedCommand{
+		Name:            cmd.Name,
+		CooldownSeconds: int64(cmd.Cooldown),
+		RlPlatform:      cmd.RlPlatform,
+		RlUsername:      cmd.RlUsername,
+		MessageFormat:   cmd.RlMessageFormat,
+	}
+	if resolved.RlPlatform == "" || resolved.RlUsername == "" {
+		resolved.RlPlatform = dbUser.RlPlatform
+		resolved.RlUsername = dbUser.RlUsername
+	}
+	if resolved.MessageFormat == "" {
+		resolved.MessageFormat = dbUser.RlMessageFormat
+	}
+	return &resolved
+}
+
+// invalidateChannelCache drops the command index and all cached commands of a channel
+// and tells the other instances to drop their in memory copy.
+func invalidateChannelCache(twitchUserId string) {
+	dropLocalChannel(twitchUserId)
+	err := invalidationBus.InvalidateTwitchChannel(twitchUserId)
+	if err != nil {
+		log.WithField("event", "channel_cache_invalidate").Error(err)
+	}
+}
+
+func listenForInvalidations() {
+	invalidationBus.On(cache.TwitchChannelChanged, func(event cache.InvalidationEvent) {
+		dropLocalChannel(event.TwitchUserId)
+	})
+	_, err := invalidationBus.Listen()
+	if err != nil {
+		log.WithField("event", "invalidation_listen").Fatal(err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+var channelManager = CommandPermission{MinRole: RoleModerator, Delegable: true}
+var onOffArg = []Arg{{Name: "on|off"}}
+
+func registerCommands() {
+	registerCommand(&Command{
+		Name:       "help",
+		Aliases:    []string{"commands"},
+		Args:       []Arg{{Name: "command", Optional: true}},
+		Permission: CommandPermission{MinRole: RoleEveryone},
+		Handler:    helpCommand,
+	})
+	registerCommand(&Command{
+		Name:       "join",
+		Permission: CommandPermission{MinRole: RoleEveryone, BotChannelOnly: true},
+		Handler:    joinChannelCommand,
+	})
+	registerCommand(&Command{
+		Name:       "leave",
+		Permission: CommandPermission{MinRole: RoleBroadcaster},
+		Handler:    leaveChannelCommand,
+	})
+	registerCommand(&Command{
+		Name:       "set",
+		Args:       []Arg{{Name: "platform"}, {Name: "username", Rest: true}},
+		Permission: channelManager,
+		Handler:    setCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setplatform",
+		Args:       []Arg{{Name: "platform"}},
+		Permission: channelManager,
+		Handler:    setPlatformCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setusername",
+		Args:       []Arg{{Name: "username", Rest: true}},
+		Permission: channelManager,
+		Handler:    setUsernameCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setformat",
+		Args:       []Arg{{Name: "format", Rest: true}},
+		Permission: channelManager,
+		Handler:    setFormatCommand,
+	})
+	registerCommand(&Command{
+		Name:       "previewformat",
+		Args:       []Arg{{Name: "format", Rest: true}},
+		Permission: channelManager,
+		Handler:    previewFormatCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setcmd",
+		Args:       []Arg{{Name: "command"}},
+		Permission: channelManager,
+		Handler:    setCmdCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setcooldown",
+		Args:       []Arg{{Name: "seconds"}},
+		Permission: channelManager,
+		Handler:    setCooldownCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setusercooldown",
+		Args:       []Arg{{Name: "seconds"}},
+		Permission: channelManager,
+		Handler:    setUserCooldownCommand,
+	})
+	registerBoolSetting(&BoolSetting{
+		Name:     "setcooldownexempt",
+		Update:   botDb.UpdateCooldownExemptByTwitchUserId,
+		OnReply:  "cooldown_exempt_on",
+		OffReply: "cooldown_exempt_off",
+	})
+	registerBoolSetting(&BoolSetting{
+		Name:        "setcooldownwhisper",
+		Update:      botDb.UpdateCooldownWhisperByTwitchUserId,
+		Available:   canWhisper,
+		Unavailable: "no_whispers",
+		OnReply:     "cooldown_whisper_on",
+		OffReply:    "cooldown_whisper_off",
+	})
+	registerBoolSetting(&BoolSetting{
+		Name:     "setlookup",
+		Update:   botDb.UpdateLookupEnabledByTwitchUserId,
+		OnReply:  "lookup_on",
+		OffReply: "lookup_off",
+	})
+	registerCommand(&Command{
+		Name:       "setlookupcooldown",
+		Args:       []Arg{{Name: "seconds"}},
+		Permission: channelManager,
+		Handler:    setLookupCooldownCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setprefix",
+		Args:       []Arg{{Name: "prefix"}},
+		Permission: channelManager,
+		Handler:    setPrefixCommand,
+	})
+	registerBoolSetting(&BoolSetting{
+		Name:     "setmentiononly",
+		Update:   botDb.UpdateMentionOnlyByTwitchUserId,
+		OnReply:  "mention_only_on",
+		OffReply: "mention_only_off",
+	})
+	registerBoolSetting(&BoolSetting{
+		Name:        "setliveonly",
+		Update:      botDb.UpdateLiveOnlyByTwitchUserId,
+		Available:   func() bool { return helix != nil },
+		Unavailable: "no_stream_status",
+		OnReply:     "live_only_on",
+		OffReply:    "live_only_off",
+	})
+	registerBoolSetting(&BoolSetting{
+		Name:        "setrankannounce",
+		Update:      botDb.UpdateRankAnnounceByTwitchUserId,
+		Available:   func() bool { return helix != nil },
+		Unavailable: "no_stream_status",
+		OnReply:     "rank_announce_on",
+		OffReply:    "rank_announce_off",
+		OnChange: func(twitchUserId string, enabled bool) {
+			if !enabled {
+				// announcements start over from the current rank when they are turned on again
+				redisCache.Delete(rankSnapshotKey(twitchUserId))
+			}
+		},
+	})
+	registerCommand(&Command{
+		Name:       "setrankannounceinterval",
+		Args:       []Arg{{Name: "seconds"}},
+		Permission: channelManager,
+		Handler:    setRankAnnounceIntervalCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setrankannounceformat",
+		Args:       []Arg{{Name: "format|reset", Rest: true}},
+		Permission: channelManager,
+		Handler:    setRankAnnounceFormatCommand,
+	})
+	registerCommand(&Command{
+		Name:       "setlang",
+		Args:       []Arg{{Name: "language"}},
+		Permission: channelManager,
+		Handler:    setLangCommand,
+	})
+	registerCommand(&Command{
+		Name:       "addcmd",
+		Args:       []Arg{{Name: "command"}, {Name: "platform", Optional: true}, {Name: "username", Optional: true, Rest: true}},
+		Permission: channelManager,
+		Handler:    addCmdCommand,
+	})
+	registerCommand(&Command{
+		Name:       "editcmd",
+		Args:       []Arg{{Name: "command"}, {Name: "platform|username|format|cooldown|aliases"}, {Name: "value", Rest: true}},
+		Permission: channelManager,
+		Handler:    editCmdCommand,
+	})
+	registerCommand(&Command{
+		Name:       "delcmd",
+		Args:       []Arg{{Name: "command"}},
+		Permission: channelManager,
+		Handler:    delCmdCommand,
+	})
+	registerCommand(&Command{
+		Name:       "listcmds",
+		Permission: channelManager,
+		Handler:    listCmdsCommand,
+	})
+	registerCommand(&Command{
+		Name:       "resetsession",
+		Permission: channelManager,
+		Handler:    resetSessionCommand,
+	})
+	registerCommand(&Command{
+		Name:       "delegate",
+		Args:       []Arg{{Name: "username"}},
+		Permission: CommandPermission{MinRole: RoleBroadcaster},
+		Handler:    delegateCommand,
+	})
+	registerCommand(&Command{
+		Name:       "undelegate",
+		Args:       []Arg{{Name: "username"}},
+		Permission: CommandPermission{MinRole: RoleBroadcaster},
+		Handler:    undelegateCommand,
+	})
+	registerCommand(&Command{
+		Name:       "delegates",
+		Permission: CommandPermission{MinRole: RoleBroadcaster},
+		Handler:    delegatesCommand,
+	})
+}
@@ -1,10 +1,27 @@
 package main
 
 type Configuration struct {
-	TwitchUsername       string `env:"TWITCH_USER"`
-	TwitchToken          string `env:"TWITCH_TOKEN"`
-	DbUri                string `json:"dbUri"`
-	CacheUrl             string `json:"cacheUrl"`
-	TrackerNetServiceUrl string `json:"trackerNetServiceUrl"`
-	DefaultFormat        string `json:"defaultFormat"`
+	TwitchUsername       string                `env:"TWITCH_USER"`
+	TwitchToken          string                `env:"TWITCH_TOKEN"`
+	TwitchClientId       string                `env:"TWITCH_CLIENT_ID"`
+	TwitchClientSecret   string                `env:"TWITCH_CLIENT_SECRET"`
+	EventSubSecret       string                `env:"EVENTSUB_SECRET"`
+	DbUri                string                `json:"dbUri"`
+	CacheUrl             string                `json:"cacheUrl"`
+	TrackerNetServiceUrl string                `json:"trackerNetServiceUrl"`
+	DefaultFormat        string                `json:"defaultFormat"`
+	Outbound             OutboundConfiguration `json:"outbound"`
+	HelixUrl             string                `json:"helixUrl"`
+	TwitchAuthUrl        string                `json:"twitchAuthUrl"`
+	EventSubCallbackUrl  string                `json:"eventSubCallbackUrl"`
+	EventSubListenAddr   string                `json:"eventSubListenAddr"`
+}
+
+// OutboundConfiguration overrides the default Twitch rate limits, e.g. for verified bots.
+type OutboundConfiguration struct {
+	GlobalLimit       int `json:"globalLimit"`
+	ModGlobalLimit    int `json:"modGlobalLimit"`
+	ChannelIntervalMs int `json:"channelIntervalMs"`
+	MaxChannelQueue   int `json:"maxChannelQueue"`
+	MaxMessageAge     int `json:"maxMessageAge"`
 }
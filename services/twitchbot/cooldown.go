@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+func cooldownKey(twitchUserId string, cmdName string) string {
+	return channelCacheKey(twitchUserId) + ":cd:" + cmdName
+}
+
+func userCooldownKey(twitchUserId string, cmdName string, userId string) string {
+	return channelCacheKey(twitchUserId) + ":cd:" + cmdName + ":" + userId
+}
+
+func cooldownWhisperKey(twitchUserId string, userId string) string {
+	return channelCacheKey(twitchUserId) + ":cdw:" + userId
+}
+
+// isCooldownExempt reports whether the chatter is the broadcaster, a moderator or a VIP of the channel.
+func isCooldownExempt(message *twitch.PrivateMessage) bool {
+	return message.User.Badges["broadcaster"] > 0 || message.User.Badges["moderator"] > 0 || message.User.Badges["vip"] > 0
+}
+
+// startCommandCooldown starts the channel wide and the per chatter cooldown of a command in one step.
+// It returns false if the command is still on cooldown and must not be executed.
+func startCommandCooldown(message *twitch.PrivateMessage, channel *CachedChannel, cmd *CachedCommand) bool {
+	if channel.CdExempt && isCooldownExempt(message) {
+		return true
+	}
+
+	remaining, err := redisCache.StartCooldowns(map[string]time.Duration{
+		cooldownKey(message.RoomID, cmd.Name):                      time.Second * time.Duration(cmd.CooldownSeconds),
+		userCooldownKey(message.RoomID, cmd.Name, message.User.ID): time.Second * time.Duration(channel.UserCooldown),
+	})
+	if err != nil {
+		log.WithField("event", "command_cooldown").Error(err)
+		return false
+	}
+	if remaining <= 0 {
+		return true
+	}
+
+	metricCommandsOnCooldown.Inc()
+	if channel.CdWhisper {
+		// only whisper once per cooldown to not spam the chatter
+		firstNotice, err := redisCache.SetIfAbsent(cooldownWhisperKey(message.RoomID, message.User.ID), "1", remaining)
+		if err == nil && firstNotice {
+			seconds := int64(remaining.Round(time.Second) / time.Second)
+			if seconds < 1 {
+				seconds = 1
+			}
+			go sendWhisper(message.User.ID, translate(channel.Language, "cooldown_whisper", channel.Prefix+cmd.Name, message.Channel, strconv.FormatInt(seconds, 10)))
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/rankformat"
+	"strconv"
+	"strings"
+)
+
+const maxChannelCommands = 20
+const maxCommandAliases = 5
+
+func normalizeCommandName(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "!"))
+}
+
+func isValidCommandName(name string) bool {
+	return name != "" && len(name) <= 50 && !strings.ContainsAny(name, " !")
+}
+
+// commandManagementTarget returns the channel owner the management command applies to.
+func commandManagementTarget(message *twitch.PrivateMessage) (string, *BotUser, error) {
+	twitchUserId := channelOwnerId(message)
+	dbUser, err := botDb.GetBotUserByTwitchUserId(twitchUserId)
+	return twitchUserId, dbUser, err
+}
+
+// usedTriggers returns all command names and aliases of a channel except the ones of the excluded command.
+// The names of the management commands are always used.
+func usedTriggers(dbUser *BotUser, commands []ChannelCommand, exclude string) map[string]bool {
+	triggers := map[string]bool{strings.ToLower(dbUser.TwitchCommandName): true}
+	for name := range commandRegistry {
+		triggers[name] = true
+	}
+	for _, cmd := range commands {
+		if cmd.Name == exclude {
+			continue
+		}
+		triggers[cmd.Name] = true
+		for _, alias := range cmd.Aliases {
+			triggers[alias] = true
+		}
+	}
+	return triggers
+}
+
+func findChannelCommand(commands []ChannelCommand, name string) *ChannelCommand {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func addCmdCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "addcmd_command").WithField("channel", message.Channel).Info("Executing addcmd command")
+	newCmd := ChannelCommand{Name: normalizeCommandName(args[0]), Aliases: []string{}, Cooldown: 10}
+	if !isValidCommandName(newCmd.Name) {
+		reply(message, "command_name_invalid")
+		return
+	}
+	if len(args) == 2 {
+		reply(message, "syntax", "!addcmd command [platform username]")
+		return
+	}
+	if len(args) == 3 {
+		args[1] = strings.ToLower(args[1])
+		if !validPlatforms[args[1]] {
+			reply(message, "valid_platforms")
+			return
+		}
+		if len(args[2]) > 255 {
+			reply(message, "username_too_long")
+			return
+		}
+		newCmd.RlPlatform = args[1]
+		newCmd.RlUsername = args[2]
+	}
+
+	twitchUserId, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	newCmd.TwitchUserId = dbUser.TwitchUserId
+	newCmd.Cooldown = dbUser.TwitchCommandCooldown
+
+	commands, err := botDb.GetChannelCommands(dbUser.TwitchUserId)
+	if err != nil {
+		reply(message, "command_add_error")
+		log.WithField("event", "addcmd_command_db_get").Error(err)
+		return
+	}
+	if len(commands) >= maxChannelCommands {
+		reply(message, "too_many_commands", strconv.Itoa(maxChannelCommands))
+		return
+	}
+	if usedTriggers(dbUser, commands, "")[newCmd.Name] {
+		reply(message, "command_exists", "!"+newCmd.Name)
+		return
+	}
+
+	err = botDb.InsertChannelCommand(newCmd)
+	if err != nil {
+		reply(message, "command_add_error")
+		log.WithField("event", "addcmd_command_db_insert").Error(err)
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	reply(message, "command_added", "!"+newCmd.Name, "!editcmd "+newCmd.Name+" platform|username|format|cooldown|aliases value")
+}
+
+func editCmdCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "editcmd_command").WithField("channel", message.Channel).Info("Executing editcmd command")
+	syntax := "!editcmd command platform|username|format|cooldown|aliases value"
+	name := normalizeCommandName(args[0])
+	field := strings.ToLower(args[1])
+	newValue := strings.TrimSpace(args[2])
+
+	twitchUserId, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	if name == strings.ToLower(dbUser.TwitchCommandName) {
+		reply(message, "main_command_edit")
+		return
+	}
+
+	commands, err := botDb.GetChannelCommands(dbUser.TwitchUserId)
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "editcmd_command_db_get").Error(err)
+		return
+	}
+	cmd := findChannelCommand(commands, name)
+	if cmd == nil {
+		reply(message, "command_not_found", "!"+name)
+		return
+	}
+
+	// "default" resets player and format to the channel settings
+	switch field {
+	case "platform":
+		newValue = strings.ToLower(newValue)
+		if newValue == "default" {
+			newValue = ""
+		} else if !validPlatforms[newValue] {
+			reply(message, "valid_platforms")
+			return
+		}
+		cmd.RlPlatform = newValue
+	case "username":
+		if newValue == "default" {
+			newValue = ""
+		} else if len(newValue) > 255 {
+			reply(message, "username_too_long")
+			return
+		}
+		cmd.RlUsername = newValue
+	case "format":
+		if newValue == "default" {
+			newValue = ""
+		} else if _, err := rankformat.Parse(newValue); err != nil {
+			reply(message, "invalid_format", err.Error())
+			return
+		}
+		cmd.RlMessageFormat = newValue
+	case "cooldown":
+		newCooldown, err := strconv.ParseInt(newValue, 10, 0)
+		if err != nil || newCooldown < 10 {
+			reply(message, "cooldown_invalid", "10")
+			return
+		}
+		cmd.Cooldown = int(newCooldown)
+	case "aliases":
+		aliases := make([]string, 0)
+		if newValue != "none" {
+			triggers := usedTriggers(dbUser, commands, cmd.Name)
+			for _, alias := range strings.FieldsFunc(newValue, func(r rune) bool { return r == ' ' || r == ',' }) {
+				alias = normalizeCommandName(alias)
+				if !isValidCommandName(alias) || alias == cmd.Name {
+					continue
+				}
+				if triggers[alias] {
+					reply(message, "command_exists", "!"+alias)
+					return
+				}
+				aliases = append(aliases, alias)
+			}
+		}
+		if len(aliases) > maxCommandAliases {
+			reply(message, "too_many_aliases", strconv.Itoa(maxCommandAliases))
+			return
+		}
+		cmd.Aliases = aliases
+	default:
+		reply(message, "syntax", syntax)
+		return
+	}
+
+	_, err = botDb.UpdateChannelCommand(*cmd)
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "editcmd_command_db_update").Error(err)
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	reply(message, "command_edited", "!"+cmd.Name)
+}
+
+func delCmdCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "delcmd_command").WithField("channel", message.Channel).Info("Executing delcmd command")
+	name := normalizeCommandName(args[0])
+
+	twitchUserId, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	if name == strings.ToLower(dbUser.TwitchCommandName) {
+		reply(message, "main_command_delete")
+		return
+	}
+
+	wasDeleted, err := botDb.DeleteChannelCommand(dbUser.TwitchUserId, name)
+	if err != nil {
+		reply(message, "command_delete_error")
+		log.WithField("event", "delcmd_command_db_delete").Error(err)
+		return
+	}
+	if !wasDeleted {
+		reply(message, "command_not_found", "!"+name)
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	reply(message, "command_deleted", "!"+name)
+}
+
+func listCmdsCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "listcmds_command").WithField("channel", message.Channel).Info("Executing listcmds command")
+
+	_, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	commands, err := botDb.GetChannelCommands(dbUser.TwitchUserId)
+	if err != nil {
+		reply(message, "list_error")
+		log.WithField("event", "listcmds_command_db_get").Error(err)
+		return
+	}
+
+	prefix := dbUser.CommandPrefix
+	names := []string{prefix + dbUser.TwitchCommandName}
+	for _, cmd := range commands {
+		name := prefix + cmd.Name
+		if len(cmd.Aliases) > 0 {
+			name += " (" + prefix + strings.Join(cmd.Aliases, ", "+prefix) + ")"
+		}
+		names = append(names, name)
+	}
+	reply(message, "commands", strings.Join(names, ", "))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
 )
 
 type BotDb struct {
@@ -19,6 +20,39 @@ type BotUser struct {
 	RlPlatform            string
 	RlUsername            string
 	RlMessageFormat       string
+	LookupEnabled         bool
+	LookupCooldown        int
+	UserCooldown          int
+	CooldownExempt        bool
+	CooldownWhisper       bool
+	CommandPrefix         string
+	MentionOnly           bool
+	Language              string
+	LiveOnly              bool
+	RankAnnounce          bool
+	RankAnnounceInterval  int
+	RankAnnounceFormat    string
+}
+
+const botUserColumns = `twitch_user_id, twitch_login, twitch_command_name, twitch_command_cooldown, rl_platform, rl_username,
+	rl_message_format, lookup_enabled, lookup_cooldown, user_cooldown, cooldown_exempt, cooldown_whisper,
+	command_prefix, mention_only, language, live_only, rank_announce, rank_announce_interval, rank_announce_format`
+
+// ChannelCommand is an additional rank command of a channel. Empty player or format fields fall back to the channel settings.
+type ChannelCommand struct {
+	TwitchUserId    string
+	Name            string
+	Aliases         []string
+	RlPlatform      string
+	RlUsername      string
+	RlMessageFormat string
+	Cooldown        int
+}
+
+// ChannelDelegate is a user allowed to manage the bot in a channel. Login is the login at the time the delegate was added.
+type ChannelDelegate struct {
+	UserId string
+	Login  string
 }
 
 func NewBotDb(uri string) (*BotDb, error) {
@@ -33,44 +67,102 @@ func NewBotDb(uri string) (*BotDb, error) {
 }
 
 func (db *BotDb) GetBotUserByTwitchUserId(twitchUserId string) (*BotUser, error) {
-	row := db.pool.QueryRow(db.ctx, `select twitch_user_id, twitch_login, twitch_command_name, twitch_command_cooldown, 
-		rl_platform, rl_username, rl_message_format from users_twitch where twitch_user_id=$1;`, twitchUserId)
+	row := db.pool.QueryRow(db.ctx, `select `+botUserColumns+` from users_twitch where twitch_user_id=$1;`, twitchUserId)
 	return toBotUser(row)
 }
 
-func (db *BotDb) GetBotUserByTwitchLogin(twitchLogin string) (*BotUser, error) {
-	row := db.pool.QueryRow(db.ctx, `select twitch_user_id, twitch_login, twitch_command_name, twitch_command_cooldown,
-		rl_platform, rl_username, rl_message_format from users_twitch where twitch_login=$1;`, twitchLogin)
-	return toBotUser(row)
+func (db *BotDb) UpdateRlPlatformAndUsernameByTwitchUserId(twitchUserId string, rlPlatform string, rlUsername string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_platform=$1, rl_username=$2 where twitch_user_id=$3;`, rlPlatform, rlUsername, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateRlPlatformByTwitchUserId(twitchUserId string, rlPlatform string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_platform=$1 where twitch_user_id=$2;`, rlPlatform, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateRlUsernameByTwitchUserId(twitchUserId string, rlUsername string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_username=$1 where twitch_user_id=$2;`, rlUsername, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateRlMsgFormatByTwitchUserId(twitchUserId string, format string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_message_format=$1 where twitch_user_id=$2;`, format, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateTwitchCommandNameByTwitchUserId(twitchUserId string, cmd string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set twitch_command_name=$1 where twitch_user_id=$2;`, cmd, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateTwitchCommandCooldownByTwitchUserId(twitchUserId string, cooldown int) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set twitch_command_cooldown=$1 where twitch_user_id=$2;`, cooldown, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateLookupEnabledByTwitchUserId(twitchUserId string, enabled bool) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set lookup_enabled=$1 where twitch_user_id=$2;`, enabled, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateLookupCooldownByTwitchUserId(twitchUserId string, cooldown int) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set lookup_cooldown=$1 where twitch_user_id=$2;`, cooldown, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateUserCooldownByTwitchUserId(twitchUserId string, cooldown int) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set user_cooldown=$1 where twitch_user_id=$2;`, cooldown, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
 }
 
-func (db *BotDb) UpdateRlPlatformAndUsernameByTwitchLogin(twitchLogin string, rlPlatform string, rlUsername string) (bool, error) {
-	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_platform=$1, rl_username=$2 where twitch_login=$3;`, rlPlatform, rlUsername, twitchLogin)
+func (db *BotDb) UpdateCooldownExemptByTwitchUserId(twitchUserId string, exempt bool) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set cooldown_exempt=$1 where twitch_user_id=$2;`, exempt, twitchUserId)
 	return cmdTag.RowsAffected() > 0, err
 }
 
-func (db *BotDb) UpdateRlPlatformByTwitchLogin(twitchLogin string, rlPlatform string) (bool, error) {
-	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_platform=$1 where twitch_login=$2;`, rlPlatform, twitchLogin)
+func (db *BotDb) UpdateCooldownWhisperByTwitchUserId(twitchUserId string, whisper bool) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set cooldown_whisper=$1 where twitch_user_id=$2;`, whisper, twitchUserId)
 	return cmdTag.RowsAffected() > 0, err
 }
 
-func (db *BotDb) UpdateRlUsernameByTwitchLogin(twitchLogin string, rlUsername string) (bool, error) {
-	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_username=$1 where twitch_login=$2;`, rlUsername, twitchLogin)
+func (db *BotDb) UpdateCommandPrefixByTwitchUserId(twitchUserId string, prefix string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set command_prefix=$1 where twitch_user_id=$2;`, prefix, twitchUserId)
 	return cmdTag.RowsAffected() > 0, err
 }
 
-func (db *BotDb) UpdateRlMsgFormatByTwitchLogin(twitchLogin string, format string) (bool, error) {
-	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rl_message_format=$1 where twitch_login=$2;`, format, twitchLogin)
+func (db *BotDb) UpdateMentionOnlyByTwitchUserId(twitchUserId string, mentionOnly bool) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set mention_only=$1 where twitch_user_id=$2;`, mentionOnly, twitchUserId)
 	return cmdTag.RowsAffected() > 0, err
 }
 
-func (db *BotDb) UpdateTwitchCommandNameByTwitchLogin(twitchLogin string, cmd string) (bool, error) {
-	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set twitch_command_name=$1 where twitch_login=$2;`, cmd, twitchLogin)
+func (db *BotDb) UpdateLanguageByTwitchUserId(twitchUserId string, language string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set language=$1 where twitch_user_id=$2;`, language, twitchUserId)
 	return cmdTag.RowsAffected() > 0, err
 }
 
-func (db *BotDb) UpdateTwitchCommandCooldownByTwitchLogin(twitchLogin string, cooldown int) (bool, error) {
-	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set twitch_command_cooldown=$1 where twitch_login=$2;`, cooldown, twitchLogin)
+func (db *BotDb) UpdateLiveOnlyByTwitchUserId(twitchUserId string, liveOnly bool) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set live_only=$1 where twitch_user_id=$2;`, liveOnly, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateRankAnnounceByTwitchUserId(twitchUserId string, enabled bool) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rank_announce=$1 where twitch_user_id=$2;`, enabled, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateRankAnnounceIntervalByTwitchUserId(twitchUserId string, interval int) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rank_announce_interval=$1 where twitch_user_id=$2;`, interval, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateRankAnnounceFormatByTwitchUserId(twitchUserId string, format string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set rank_announce_format=$1 where twitch_user_id=$2;`, format, twitchUserId)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) UpdateTwitchLoginByTwitchUserId(twitchUserId string, twitchLogin string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update users_twitch set twitch_login=$1 where twitch_user_id=$2;`, twitchLogin, twitchUserId)
 	return cmdTag.RowsAffected() > 0, err
 }
 
@@ -109,9 +201,188 @@ func (db *BotDb) GetUserNames(userNameGt string, pageSize int) ([]string, *strin
 	return loginNames, nil, nil
 }
 
+func (db *BotDb) GetTwitchUserIds() ([]string, error) {
+	rows, err := db.pool.Query(db.ctx, `select twitch_user_id from users_twitch order by twitch_user_id asc;`)
+	userIds := make([]string, 0)
+	if err != nil {
+		return userIds, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId string
+		err = rows.Scan(&userId)
+		if err != nil {
+			return userIds, err
+		}
+		userIds = append(userIds, userId)
+	}
+	return userIds, nil
+}
+
+func (db *BotDb) GetRankAnnounceUsers() ([]*BotUser, error) {
+	rows, err := db.pool.Query(db.ctx, `select `+botUserColumns+` from users_twitch where rank_announce;`)
+	users := make([]*BotUser, 0)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := toBotUser(rows)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// GetTwitchLogins maps the user id of every joined channel to its stored login.
+func (db *BotDb) GetTwitchLogins() (map[string]string, error) {
+	rows, err := db.pool.Query(db.ctx, `select twitch_user_id, twitch_login from users_twitch;`)
+	logins := make(map[string]string)
+	if err != nil {
+		return logins, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId, login string
+		err = rows.Scan(&userId, &login)
+		if err != nil {
+			return logins, err
+		}
+		logins[userId] = login
+	}
+	return logins, nil
+}
+
+func (db *BotDb) ReplaceSessionBaseline(twitchUserId string, rankings []trackernet.Ranking) error {
+	tx, err := db.pool.Begin(db.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.ctx)
+
+	_, err = tx.Exec(db.ctx, `delete from twitch_session_baselines where twitch_user_id=$1;`, twitchUserId)
+	if err != nil {
+		return err
+	}
+	for _, ranking := range rankings {
+		_, err = tx.Exec(db.ctx, `insert into twitch_session_baselines (twitch_user_id, playlist, mmr) values ($1, $2, $3);`,
+			twitchUserId, string(ranking.Playlist), ranking.Mmr)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(db.ctx)
+}
+
+func (db *BotDb) GetSessionBaseline(twitchUserId string) (map[trackernet.Playlist]int, error) {
+	rows, err := db.pool.Query(db.ctx, `select playlist, mmr from twitch_session_baselines where twitch_user_id=$1;`, twitchUserId)
+	baseline := make(map[trackernet.Playlist]int)
+	if err != nil {
+		return baseline, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var playlist string
+		var mmr int
+		err = rows.Scan(&playlist, &mmr)
+		if err != nil {
+			return baseline, err
+		}
+		baseline[trackernet.Playlist(playlist)] = mmr
+	}
+	return baseline, rows.Err()
+}
+
+func (db *BotDb) GetChannelCommands(twitchUserId string) ([]ChannelCommand, error) {
+	rows, err := db.pool.Query(db.ctx, `select twitch_user_id, command_name, aliases, coalesce(rl_platform, ''), coalesce(rl_username, ''),
+		coalesce(rl_message_format, ''), cooldown from channel_commands where twitch_user_id=$1 order by command_name asc;`, twitchUserId)
+	commands := make([]ChannelCommand, 0)
+	if err != nil {
+		return commands, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cmd := ChannelCommand{}
+		err = rows.Scan(&cmd.TwitchUserId, &cmd.Name, &cmd.Aliases, &cmd.RlPlatform, &cmd.RlUsername, &cmd.RlMessageFormat, &cmd.Cooldown)
+		if err != nil {
+			return commands, err
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, rows.Err()
+}
+
+func (db *BotDb) InsertChannelCommand(cmd ChannelCommand) error {
+	_, err := db.pool.Exec(db.ctx, `insert into channel_commands (twitch_user_id, command_name, aliases, rl_platform, rl_username, rl_message_format, cooldown)
+		values ($1, $2, $3, nullif($4, ''), nullif($5, ''), nullif($6, ''), $7);`,
+		cmd.TwitchUserId, cmd.Name, cmd.Aliases, cmd.RlPlatform, cmd.RlUsername, cmd.RlMessageFormat, cmd.Cooldown)
+	return err
+}
+
+func (db *BotDb) UpdateChannelCommand(cmd ChannelCommand) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `update channel_commands set aliases=$3, rl_platform=nullif($4, ''), rl_username=nullif($5, ''),
+		rl_message_format=nullif($6, ''), cooldown=$7 where twitch_user_id=$1 and command_name=$2;`,
+		cmd.TwitchUserId, cmd.Name, cmd.Aliases, cmd.RlPlatform, cmd.RlUsername, cmd.RlMessageFormat, cmd.Cooldown)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) DeleteChannelCommand(twitchUserId string, name string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `delete from channel_commands where twitch_user_id=$1 and command_name=$2;`, twitchUserId, name)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+func (db *BotDb) IsChannelDelegate(twitchUserId string, delegateUserId string) (bool, error) {
+	var exists bool
+	err := db.pool.QueryRow(db.ctx, `select exists(select 1 from channel_delegates where twitch_user_id=$1 and delegate_user_id=$2);`,
+		twitchUserId, delegateUserId).Scan(&exists)
+	return exists, err
+}
+
+func (db *BotDb) GetChannelDelegates(twitchUserId string) ([]ChannelDelegate, error) {
+	rows, err := db.pool.Query(db.ctx, `select delegate_user_id, delegate_login from channel_delegates where twitch_user_id=$1 order by delegate_login asc;`, twitchUserId)
+	delegates := make([]ChannelDelegate, 0)
+	if err != nil {
+		return delegates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delegate ChannelDelegate
+		err = rows.Scan(&delegate.UserId, &delegate.Login)
+		if err != nil {
+			return delegates, err
+		}
+		delegates = append(delegates, delegate)
+	}
+	return delegates, rows.Err()
+}
+
+func (db *BotDb) InsertChannelDelegate(twitchUserId string, delegateUserId string, login string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `insert into channel_delegates (twitch_user_id, delegate_user_id, delegate_login) values ($1, $2, $3) on conflict do nothing;`,
+		twitchUserId, delegateUserId, login)
+	return cmdTag.RowsAffected() > 0, err
+}
+
+// DeleteChannelDelegate removes a delegate by user id, or by the stored login for accounts that no longer exist.
+func (db *BotDb) DeleteChannelDelegate(twitchUserId string, delegateUserId string, login string) (bool, error) {
+	cmdTag, err := db.pool.Exec(db.ctx, `delete from channel_delegates where twitch_user_id=$1 and (delegate_user_id=$2 or delegate_login=$3);`,
+		twitchUserId, delegateUserId, login)
+	return cmdTag.RowsAffected() > 0, err
+}
+
 func toBotUser(row pgx.Row) (*BotUser, error) {
 	user := BotUser{}
-	err := row.Scan(&user.TwitchUserId, &user.TwitchLogin, &user.TwitchCommandName, &user.TwitchCommandCooldown, &user.RlPlatform, &user.RlUsername, &user.RlMessageFormat)
+	err := row.Scan(&user.TwitchUserId, &user.TwitchLogin, &user.TwitchCommandName, &user.TwitchCommandCooldown, &user.RlPlatform, &user.RlUsername,
+		&user.RlMessageFormat, &user.LookupEnabled, &user.LookupCooldown,
+		&user.UserCooldown, &user.CooldownExempt, &user.CooldownWhisper, &user.CommandPrefix, &user.MentionOnly,
+		&user.Language, &user.LiveOnly, &user.RankAnnounce, &user.RankAnnounceInterval, &user.RankAnnounceFormat)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	eventSubStreamOnline  = "stream.online"
+	eventSubStreamOffline = "stream.offline"
+	eventSubMaxAge        = time.Minute * 10
+	liveStatusTtl         = time.Hour * 12
+	polledLiveStatusTtl   = time.Minute * 2
+)
+
+// StreamEvent is the payload of a stream.online or stream.offline notification.
+type StreamEvent struct {
+	BroadcasterUserId    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	Type                 string `json:"type"`
+}
+
+type StreamEventHandler func(event StreamEvent)
+
+var streamOnlineHandlers = make([]StreamEventHandler, 0)
+var streamOfflineHandlers = make([]StreamEventHandler, 0)
+
+// onStreamOnline registers a handler that runs whenever a joined channel goes live.
+func onStreamOnline(handler StreamEventHandler) {
+	streamOnlineHandlers = append(streamOnlineHandlers, handler)
+}
+
+// onStreamOffline registers a handler that runs whenever a joined channel ends its stream.
+func onStreamOffline(handler StreamEventHandler) {
+	streamOfflineHandlers = append(streamOfflineHandlers, handler)
+}
+
+type eventSubMessage struct {
+	Challenge    string               `json:"challenge"`
+	Subscription EventSubSubscription `json:"subscription"`
+	Event        json.RawMessage      `json:"event"`
+}
+
+func liveStatusKey(twitchUserId string) string {
+	return channelCacheKey(twitchUserId) + ":live"
+}
+
+func eventSubMessageKey(messageId string) string {
+	return "twitch:eventsub:" + messageId
+}
+
+func isEventSubEnabled() bool {
+	return helix != nil && configuration.TwitchClientSecret != "" && configuration.EventSubCallbackUrl != "" && configuration.EventSubSecret != ""
+}
+
+// verifyEventSubSignature checks the HMAC Twitch computes over the message id, timestamp and body with the subscription secret.
+func verifyEventSubSignature(header http.Header, body []byte) bool {
+	mac := hmac.New(sha256.New, []byte(configuration.EventSubSecret))
+	mac.Write([]byte(header.Get("Twitch-Eventsub-Message-Id")))
+	mac.Write([]byte(header.Get("Twitch-Eventsub-Message-Timestamp")))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(header.Get("Twitch-Eventsub-Message-Signature")))
+}
+
+func handleEventSub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !verifyEventSubSignature(r.Header, body) {
+		log.WithField("event", "eventsub_signature").Warn("Rejected EventSub message with invalid signature")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// reject replayed messages
+	sentAt, err := time.Parse(time.RFC3339Nano, r.Header.Get("Twitch-Eventsub-Message-Timestamp"))
+	if err != nil || time.Since(sentAt) > eventSubMaxAge {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var msg eventSubMessage
+	err = json.Unmarshal(body, &msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch r.Header.Get("Twitch-Eventsub-Message-Type") {
+	case "webhook_callback_verification":
+		log.WithField("event", "eventsub_verification").WithField("type", msg.Subscription.Type).
+			WithField("broadcaster", msg.Subscription.Condition.BroadcasterUserId).Info("Verified EventSub subscription")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(msg.Challenge))
+	case "revocation":
+		log.WithField("event", "eventsub_revocation").WithField("type", msg.Subscription.Type).
+			WithField("broadcaster", msg.Subscription.Condition.BroadcasterUserId).Warn("EventSub subscription revoked: " + msg.Subscription.Status)
+		w.WriteHeader(http.StatusNoContent)
+	case "notification":
+		w.WriteHeader(http.StatusNoContent)
+		// Twitch retries notifications that were not acknowledged in time
+		firstDelivery, err := redisCache.SetIfAbsent(eventSubMessageKey(r.Header.Get("Twitch-Eventsub-Message-Id")), "1", eventSubMaxAge)
+		if err != nil {
+			log.WithField("event", "eventsub_dedupe").Error(err)
+		} else if !firstDelivery {
+			return
+		}
+		metricEventSubNotifications.WithLabelValues(msg.Subscription.Type).Inc()
+
+		var event StreamEvent
+		err = json.Unmarshal(msg.Event, &event)
+		if err != nil {
+			log.WithField("event", "eventsub_notification").Error(err)
+			return
+		}
+		go dispatchStreamEvent(msg.Subscription.Type, event)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func dispatchStreamEvent(subscriptionType string, event StreamEvent) {
+	var handlers []StreamEventHandler
+	switch subscriptionType {
+	case eventSubStreamOnline:
+		handlers = streamOnlineHandlers
+	case eventSubStreamOffline:
+		handlers = streamOfflineHandlers
+	default:
+		return
+	}
+	log.WithField("event", "stream_event").WithField("type", subscriptionType).WithField("channel", event.BroadcasterUserLogin).Info("Received stream event")
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+func startEventSubServer() {
+	eventSubServer := http.NewServeMux()
+	eventSubServer.HandleFunc("/eventsub", handleEventSub)
+	go func() {
+		err := http.ListenAndServe(configuration.EventSubListenAddr, eventSubServer)
+		if err != nil {
+			log.WithField("event", "start_eventsub_server").Fatal(err)
+		}
+	}()
+	log.WithField("event", "start_eventsub_server").Info("EventSub server started")
+}
+
+func setLiveStatus(twitchUserId string, live bool, ttl time.Duration) {
+	value := "0"
+	if live {
+		value = "1"
+	}
+	err := redisCache.SetWithTtl(liveStatusKey(twitchUserId), value, ttl)
+	if err != nil {
+		log.WithField("event", "live_status_set").Error(err)
+	}
+}
+
+// isChannelLive reports whether a channel is streaming. Without a Helix client every channel counts as live.
+func isChannelLive(twitchUserId string) bool {
+	return liveChannels([]string{twitchUserId})[twitchUserId]
+}
+
+// liveChannels returns which of the channels are streaming, looking up the channels without a cached status in
+// batches. Without a Helix client or when a lookup fails the channels count as live.
+func liveChannels(twitchUserIds []string) map[string]bool {
+	live := make(map[string]bool, len(twitchUserIds))
+	unknown := make([]string, 0)
+	for _, twitchUserId := range twitchUserIds {
+		if helix == nil {
+			live[twitchUserId] = true
+			continue
+		}
+		cached, err := redisCache.Get(liveStatusKey(twitchUserId))
+		if err == nil {
+			live[twitchUserId] = cached == "1"
+		} else {
+			unknown = append(unknown, twitchUserId)
+		}
+	}
+
+	for start := 0; start < len(unknown); start += helixUsersPerPage {
+		end := start + helixUsersPerPage
+		if end > len(unknown) {
+			end = len(unknown)
+		}
+		batch := unknown[start:end]
+		streams, err := helix.GetStreams(batch)
+		if err != nil {
+			log.WithField("event", "live_status_get").Error(err)
+			for _, twitchUserId := range batch {
+				live[twitchUserId] = true
+			}
+			continue
+		}
+		for _, twitchUserId := range batch {
+			live[twitchUserId] = false
+		}
+		for _, stream := range streams {
+			live[stream.UserId] = true
+		}
+		for _, twitchUserId := range batch {
+			setLiveStatus(twitchUserId, live[twitchUserId], polledLiveStatusTtl)
+		}
+	}
+	return live
+}
+
+func registerStreamEventHandlers() {
+	onStreamOnline(func(event StreamEvent) {
+		setLiveStatus(event.BroadcasterUserId, true, liveStatusTtl)
+	})
+	onStreamOffline(func(event StreamEvent) {
+		setLiveStatus(event.BroadcasterUserId, false, liveStatusTtl)
+	})
+	onStreamOnline(func(event StreamEvent) {
+		dbUser, err := botDb.GetBotUserByTwitchUserId(event.BroadcasterUserId)
+		if err != nil || dbUser.RlPlatform == "" || dbUser.RlUsername == "" {
+			return
+		}
+		err = recordSessionBaseline(dbUser.TwitchUserId, dbUser.RlPlatform, dbUser.RlUsername)
+		if err != nil {
+			log.WithField("event", "stream_online_session").WithField("channel", dbUser.TwitchLogin).Warn(err)
+		}
+	})
+}
+
+func newStreamSubscription(subscriptionType string, twitchUserId string) EventSubSubscription {
+	return EventSubSubscription{
+		Type:      subscriptionType,
+		Version:   "1",
+		Condition: EventSubCondition{BroadcasterUserId: twitchUserId},
+		Transport: EventSubTransport{
+			Method:   "webhook",
+			Callback: configuration.EventSubCallbackUrl,
+			Secret:   configuration.EventSubSecret,
+		},
+	}
+}
+
+// subscribeStreamEvents creates the stream online and offline subscriptions of a channel.
+func subscribeStreamEvents(twitchUserId string) {
+	if !isEventSubEnabled() {
+		return
+	}
+	for _, subscriptionType := range []string{eventSubStreamOnline, eventSubStreamOffline} {
+		err := helix.CreateEventSubSubscription(newStreamSubscription(subscriptionType, twitchUserId))
+		if helixErr, ok := err.(HelixError); ok && helixErr.StatusCode == http.StatusConflict {
+			continue
+		}
+		if err != nil {
+			log.WithField("event", "eventsub_subscribe").WithField("broadcaster", twitchUserId).Error(err)
+		}
+	}
+}
+
+// unsubscribeStreamEvents deletes all subscriptions of a channel the bot left.
+func unsubscribeStreamEvents(twitchUserId string) {
+	if !isEventSubEnabled() {
+		return
+	}
+	subs, err := helix.GetEventSubSubscriptions()
+	if err != nil {
+		log.WithField("event", "eventsub_unsubscribe").Error(err)
+		return
+	}
+	for _, sub := range subs {
+		if sub.Condition.BroadcasterUserId != twitchUserId {
+			continue
+		}
+		err = helix.DeleteEventSubSubscription(sub.Id)
+		if err != nil {
+			log.WithField("event", "eventsub_unsubscribe").WithField("broadcaster", twitchUserId).Error(err)
+		}
+	}
+}
+
+// syncEventSubSubscriptions subscribes to the stream events of all joined channels and removes subscriptions of channels
+// that are no longer joined or that Twitch stopped delivering.
+func syncEventSubSubscriptions() {
+	userIds, err := botDb.GetTwitchUserIds()
+	if err != nil {
+		log.WithField("event", "eventsub_sync").Error(err)
+		return
+	}
+	subs, err := helix.GetEventSubSubscriptions()
+	if err != nil {
+		log.WithField("event", "eventsub_sync").Error(err)
+		return
+	}
+
+	joined := make(map[string]bool, len(userIds))
+	for _, id := range userIds {
+		joined[id] = true
+	}
+	existing := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		active := sub.Status == "enabled" || sub.Status == "webhook_callback_verification_pending"
+		if !joined[sub.Condition.BroadcasterUserId] || !active || sub.Transport.Callback != configuration.EventSubCallbackUrl {
+			err = helix.DeleteEventSubSubscription(sub.Id)
+			if err != nil {
+				log.WithField("event", "eventsub_sync").Error(err)
+			}
+			continue
+		}
+		existing[sub.Type+":"+sub.Condition.BroadcasterUserId] = true
+	}
+
+	created := 0
+	for _, id := range userIds {
+		for _, subscriptionType := range []string{eventSubStreamOnline, eventSubStreamOffline} {
+			if existing[subscriptionType+":"+id] {
+				continue
+			}
+			err = helix.CreateEventSubSubscription(newStreamSubscription(subscriptionType, id))
+			if err != nil {
+				log.WithField("event", "eventsub_sync").WithField("broadcaster", id).Error(err)
+				continue
+			}
+			created++
+		}
+	}
+	log.WithField("event", "eventsub_sync").Info("Created " + strconv.Itoa(created) + " EventSub subscriptions")
+}
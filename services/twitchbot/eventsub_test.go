@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/yannismate/yannismate-api/libs/cache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testEventSubSecret = "eventsub-secret"
+
+func signedEventSubRequest(messageType string, messageId string, sentAt time.Time, body string) *http.Request {
+	timestamp := sentAt.UTC().Format(time.RFC3339Nano)
+	mac := hmac.New(sha256.New, []byte(testEventSubSecret))
+	mac.Write([]byte(messageId + timestamp + body))
+
+	req := httptest.NewRequest(http.MethodPost, "/eventsub", strings.NewReader(body))
+	req.Header.Set("Twitch-Eventsub-Message-Id", messageId)
+	req.Header.Set("Twitch-Eventsub-Message-Timestamp", timestamp)
+	req.Header.Set("Twitch-Eventsub-Message-Type", messageType)
+	req.Header.Set("Twitch-Eventsub-Message-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestVerifyEventSubSignature(t *testing.T) {
+	configuration.EventSubSecret = testEventSubSecret
+	body := `{"challenge":"abc"}`
+	req := signedEventSubRequest("webhook_callback_verification", "msg-1", time.Now(), body)
+
+	if !verifyEventSubSignature(req.Header, []byte(body)) {
+		t.Fatal("valid signature was rejected")
+	}
+	if verifyEventSubSignature(req.Header, []byte(`{"challenge":"abd"}`)) {
+		t.Fatal("signature of a modified body was accepted")
+	}
+	req.Header.Set("Twitch-Eventsub-Message-Signature", "sha256=00")
+	if verifyEventSubSignature(req.Header, []byte(body)) {
+		t.Fatal("invalid signature was accepted")
+	}
+}
+
+func TestHandleEventSubRejectsStaleMessages(t *testing.T) {
+	configuration.EventSubSecret = testEventSubSecret
+	req := signedEventSubRequest("webhook_callback_verification", "msg-1", time.Now().Add(-eventSubMaxAge-time.Minute), `{"challenge":"abc"}`)
+
+	rec := httptest.NewRecorder()
+	handleEventSub(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a stale message, got %d", rec.Code)
+	}
+}
+
+func TestHandleEventSubAnswersChallenge(t *testing.T) {
+	configuration.EventSubSecret = testEventSubSecret
+	req := signedEventSubRequest("webhook_callback_verification", "msg-1", time.Now(),
+		`{"challenge":"pogchamp-kappa-360noscope","subscription":{"type":"stream.online","condition":{"broadcaster_user_id":"1"}}}`)
+
+	rec := httptest.NewRecorder()
+	handleEventSub(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "text/plain" || rec.Body.String() != "pogchamp-kappa-360noscope" {
+		t.Fatalf("challenge was not echoed: %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestHandleEventSubSuppressesDuplicates(t *testing.T) {
+	configuration.EventSubSecret = testEventSubSecret
+	redisServer := miniredis.RunT(t)
+	redisCache = cache.NewCache(redisServer.Addr())
+
+	received := make(chan StreamEvent, 2)
+	previousHandlers := streamOnlineHandlers
+	streamOnlineHandlers = []StreamEventHandler{func(event StreamEvent) { received <- event }}
+	defer func() { streamOnlineHandlers = previousHandlers }()
+
+	body := `{"subscription":{"type":"stream.online","condition":{"broadcaster_user_id":"1"}},` +
+		`"event":{"broadcaster_user_id":"1","broadcaster_user_login":"streamer","type":"live"}}`
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handleEventSub(rec, signedEventSubRequest("notification", "msg-1", time.Now(), body))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204 for delivery %d, got %d", i+1, rec.Code)
+		}
+	}
+
+	select {
+	case event := <-received:
+		if event.BroadcasterUserId != "1" {
+			t.Fatalf("unexpected event %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notification was not dispatched")
+	}
+	select {
+	case <-received:
+		t.Fatal("retried notification was dispatched twice")
+	case <-time.After(time.Millisecond * 200):
+	}
+}
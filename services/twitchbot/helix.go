@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HelixClient talks to the Twitch Helix API with an app access token. Whispers are sent with the token of the bot account.
+type HelixClient struct {
+	clientId     string
+	clientSecret string
+	userToken    string
+	baseUrl      string
+	authUrl      string
+	httpClient   http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+type HelixError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e HelixError) Error() string {
+	return "helix returned status " + strconv.Itoa(e.StatusCode) + ": " + e.Message
+}
+
+type HelixUser struct {
+	Id          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name"`
+}
+
+type HelixStream struct {
+	Id        string    `json:"id"`
+	UserId    string    `json:"user_id"`
+	UserLogin string    `json:"user_login"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type EventSubCondition struct {
+	BroadcasterUserId string `json:"broadcaster_user_id"`
+}
+
+type EventSubTransport struct {
+	Method   string `json:"method"`
+	Callback string `json:"callback"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+type EventSubSubscription struct {
+	Id        string            `json:"id,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition EventSubCondition `json:"condition"`
+	Transport EventSubTransport `json:"transport"`
+}
+
+type helixPagination struct {
+	Cursor string `json:"cursor"`
+}
+
+// NewHelixClient creates a client for the app. userToken is the token of the bot account and has to be issued for clientId.
+func NewHelixClient(clientId string, clientSecret string, userToken string, baseUrl string, authUrl string) *HelixClient {
+	return &HelixClient{
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		userToken:    userToken,
+		baseUrl:      baseUrl,
+		authUrl:      authUrl,
+		httpClient:   http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// appToken returns a cached app access token, requesting a new one with the client credentials flow when it expired.
+func (h *HelixClient) appToken(forceRefresh bool) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !forceRefresh && h.token != "" && time.Now().Before(h.tokenExpiry) {
+		return h.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", h.clientId)
+	form.Set("client_secret", h.clientSecret)
+	form.Set("grant_type", "client_credentials")
+	res, err := h.httpClient.PostForm(h.authUrl+"/token", form)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", HelixError{StatusCode: res.StatusCode, Message: "app token request failed"}
+	}
+
+	var tokenRes struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&tokenRes)
+	if err != nil {
+		return "", err
+	}
+	if tokenRes.AccessToken == "" {
+		return "", errors.New("empty app access token")
+	}
+
+	h.token = tokenRes.AccessToken
+	// refresh a minute early so requests in flight do not run into the expiry
+	h.tokenExpiry = time.Now().Add(time.Second*time.Duration(tokenRes.ExpiresIn) - time.Minute)
+	return h.token, nil
+}
+
+// do sends a request with the app token and decodes the response into out. An expired token is refreshed once.
+// Without a client secret the bot's user token is used, which is enough for lookups but not for EventSub.
+func (h *HelixClient) do(method string, path string, body interface{}, out interface{}) error {
+	if h.clientSecret == "" {
+		return h.doWithToken(h.userToken, method, path, body, out)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := h.appToken(attempt > 0)
+		if err != nil {
+			return err
+		}
+		err = h.doWithToken(token, method, path, body, out)
+		if helixErr, ok := err.(HelixError); ok && helixErr.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			log.WithField("event", "helix_token_rejected").Warn("App access token rejected, refreshing")
+			continue
+		}
+		return err
+	}
+	return HelixError{StatusCode: http.StatusUnauthorized, Message: "app access token rejected"}
+}
+
+// doWithToken sends an authenticated request and decodes the response into out.
+func (h *HelixClient) doWithToken(token string, method string, path string, body interface{}, out interface{}) error {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		bodyBytes, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, h.baseUrl+path, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Client-Id", h.clientId)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "yannismate-api/services/twitchbot")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var errRes struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(resBody, &errRes)
+		return HelixError{StatusCode: res.StatusCode, Message: errRes.Message}
+	}
+	if out == nil || len(resBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resBody, out)
+}
+
+// GetUsers looks up at most 100 users by id or login.
+func (h *HelixClient) GetUsers(ids []string, logins []string) ([]HelixUser, error) {
+	query := url.Values{}
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+	for _, login := range logins {
+		query.Add("login", login)
+	}
+
+	var res struct {
+		Data []HelixUser `json:"data"`
+	}
+	err := h.do("GET", "/users?"+query.Encode(), nil, &res)
+	return res.Data, err
+}
+
+// GetStreams returns the streams of the given users that are currently live, at most 100 users per call.
+func (h *HelixClient) GetStreams(userIds []string) ([]HelixStream, error) {
+	query := url.Values{}
+	for _, id := range userIds {
+		query.Add("user_id", id)
+	}
+	query.Set("first", "100")
+
+	var res struct {
+		Data []HelixStream `json:"data"`
+	}
+	err := h.do("GET", "/streams?"+query.Encode(), nil, &res)
+	return res.Data, err
+}
+
+func (h *HelixClient) CreateEventSubSubscription(sub EventSubSubscription) error {
+	return h.do("POST", "/eventsub/subscriptions", sub, nil)
+}
+
+func (h *HelixClient) DeleteEventSubSubscription(id string) error {
+	return h.do("DELETE", "/eventsub/subscriptions?id="+url.QueryEscape(id), nil, nil)
+}
+
+// GetEventSubSubscriptions returns all subscriptions of the app, following the pagination cursor.
+func (h *HelixClient) GetEventSubSubscriptions() ([]EventSubSubscription, error) {
+	subs := make([]EventSubSubscription, 0)
+	cursor := ""
+	for {
+		path := "/eventsub/subscriptions"
+		if cursor != "" {
+			path += "?after=" + url.QueryEscape(cursor)
+		}
+
+		var res struct {
+			Data       []EventSubSubscription `json:"data"`
+			Pagination helixPagination        `json:"pagination"`
+		}
+		err := h.do("GET", path, nil, &res)
+		if err != nil {
+			return subs, err
+		}
+		subs = append(subs, res.Data...)
+		if res.Pagination.Cursor == "" {
+			return subs, nil
+		}
+		cursor = res.Pagination.Cursor
+	}
+}
+
+// SendWhisper whispers a message from the bot account. Whispers need the user:manage:whispers scope on the user token.
+func (h *HelixClient) SendWhisper(fromUserId string, toUserId string, message string) error {
+	query := url.Values{}
+	query.Set("from_user_id", fromUserId)
+	query.Set("to_user_id", toUserId)
+	body := map[string]string{"message": message}
+	return h.doWithToken(h.userToken, "POST", "/whispers?"+query.Encode(), body, nil)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHelixRefreshesRejectedAppToken(t *testing.T) {
+	var tokenRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/token":
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+				t.Errorf("unexpected token request %v", r.Form)
+			}
+			n := atomic.AddInt32(&tokenRequests, 1)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token-" + strconv.Itoa(int(n)), "expires_in": 3600})
+		case "/helix/users":
+			if r.Header.Get("Client-Id") != "client" {
+				t.Errorf("missing client id header")
+			}
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": []HelixUser{{Id: "1", Login: "streamer"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewHelixClient("client", "secret", "user-token", server.URL+"/helix", server.URL+"/auth")
+	users, err := client.GetUsers([]string{"1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Login != "streamer" {
+		t.Fatalf("unexpected users %v", users)
+	}
+	if tokenRequests != 2 {
+		t.Fatalf("expected the rejected token to be refreshed once, got %d token requests", tokenRequests)
+	}
+
+	// the refreshed token is cached
+	_, err = client.GetUsers([]string{"1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenRequests != 2 {
+		t.Fatalf("expected the cached token to be reused, got %d token requests", tokenRequests)
+	}
+}
+
+func TestHelixGetEventSubSubscriptionsFollowsCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth/token" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": 3600})
+			return
+		}
+		res := map[string]interface{}{}
+		switch r.URL.Query().Get("after") {
+		case "":
+			res["data"] = []EventSubSubscription{{Id: "a", Type: eventSubStreamOnline}, {Id: "b", Type: eventSubStreamOffline}}
+			res["pagination"] = map[string]string{"cursor": "page2"}
+		case "page2":
+			res["data"] = []EventSubSubscription{{Id: "c", Type: eventSubStreamOnline}}
+			res["pagination"] = map[string]string{}
+		default:
+			t.Errorf("unexpected cursor %s", r.URL.Query().Get("after"))
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	client := NewHelixClient("client", "secret", "user-token", server.URL+"/helix", server.URL+"/auth")
+	subs, err := client.GetEventSubSubscriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 3 || subs[0].Id != "a" || subs[2].Id != "c" {
+		t.Fatalf("unexpected subscriptions %v", subs)
+	}
+}
+
+func TestHelixUsesUserTokenWithoutSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer user-token" {
+			t.Errorf("unexpected authorization %q for %s", r.Header.Get("Authorization"), r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/helix/users":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": []HelixUser{{Id: "1", Login: "streamer"}}})
+		case "/helix/whispers":
+			if r.URL.Query().Get("from_user_id") != "2" || r.URL.Query().Get("to_user_id") != "1" {
+				t.Errorf("unexpected whisper query %v", r.URL.Query())
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewHelixClient("client", "", "user-token", server.URL+"/helix", server.URL+"/auth")
+	users, err := client.GetUsers(nil, []string{"streamer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Id != "1" {
+		t.Fatalf("unexpected users %v", users)
+	}
+	if err := client.SendWhisper("2", "1", "hi"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"github.com/gempir/go-twitch-irc/v3"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultLanguage = "en"
+
+// catalog contains all responses of the bot by language. {0}, {1}, ... are replaced with the arguments in order.
+// Messages missing in a language fall back to English.
+var catalog = map[string]map[string]string{
+	"en": {
+		"not_joined":              "The bot is not joined",
+		"already_joined":          "The bot has already joined your channel.",
+		"join_error":              "Error joining channel {0}",
+		"joined":                  "The bot has now joined your channel!",
+		"leave_error":             "Error leaving channel {0}",
+		"leaving":                 "Leaving channel {0}",
+		"not_joined_channel":      "The bot was not joined to channel {0}",
+		"syntax":                  "Syntax: \"{0}\"",
+		"unknown_command":         "Unknown command {0}",
+		"help":                    "{0} - {1}",
+		"help_list":               "Commands: {0}. Details with \"!help command\"",
+		"valid_platforms":         "Valid platforms: epic, steam, ps, xbox",
+		"username_too_long":       "This username is too long.",
+		"invalid_format":          "Invalid format. {0}",
+		"update_error":            "There was an error updating the settings",
+		"list_error":              "There was an error loading the list",
+		"setup_first":             "Please set a platform and username first",
+		"player_not_found":        "Player {0} was not found on platform {1}",
+		"rank_error":              "There was an error getting the rank for player {0} on platform {1}",
+		"setup_incomplete":        "Please complete the setup with \"@{0} !set platform username\"",
+		"setup_platform":          "Please set your platform with \"@{0} !setplatform platform\"",
+		"setup_username":          "Please set your username with \"@{0} !setusername username\"",
+		"platform_username_set":   "Platform and username updated",
+		"platform_set":            "Platform updated",
+		"username_set":            "Username updated",
+		"format_set":              "Format updated",
+		"preview":                 "Preview: {0}",
+		"preview_error":           "There was an error rendering the preview",
+		"command_too_long":        "This command is too long.",
+		"command_exists":          "The command {0} already exists.",
+		"command_set":             "Command updated to {0}",
+		"cooldown_invalid":        "The cooldown has to be a positive integer of at least {0}.",
+		"cooldown_set":            "Cooldown updated to {0} seconds",
+		"lookup_on":               "Viewers can now look up players with \"!command platform username\"",
+		"lookup_off":              "Player lookups disabled",
+		"lookup_cooldown_set":     "Lookup cooldown updated to {0} seconds",
+		"user_cooldown_invalid":   "The user cooldown has to be an integer between 0 and 86400.",
+		"user_cooldown_off":       "User cooldown disabled",
+		"user_cooldown_set":       "User cooldown updated to {0} seconds",
+		"cooldown_exempt_on":      "The broadcaster, mods and VIPs now ignore cooldowns",
+		"cooldown_exempt_off":     "Cooldowns now apply to everyone",
+		"cooldown_whisper_on":     "Chatters on cooldown will now be whispered the remaining time",
+		"cooldown_whisper_off":    "Cooldown whispers disabled",
+		"no_whispers":             "Whispers are not available on this bot",
+		"cooldown_whisper":        "{0} in #{1} is on cooldown for another {2} seconds",
+		"prefix_invalid":          "The prefix can be at most 3 characters long and must not contain letters, digits, \"@\" or start with \"/\" or \".\"",
+		"prefix_set":              "Prefix updated to {0}",
+		"mention_only_on":         "Rank commands now only respond to \"@{0} command\"",
+		"mention_only_off":        "Rank commands respond to the prefix again",
+		"live_only_on":            "Rank commands now only respond while you are live",
+		"live_only_off":           "Rank commands now also respond while you are offline",
+		"no_stream_status":        "The stream status is not available on this bot",
+		"session_reset":           "Session MMR reset",
+		"session_reset_error":     "There was an error resetting the session",
+		"language_invalid":        "Supported languages: {0}",
+		"language_set":            "Language set to English",
+		"command_name_invalid":    "Command names can be at most 50 characters long and must not contain spaces.",
+		"command_add_error":       "There was an error adding the command",
+		"too_many_commands":       "A channel can have at most {0} additional commands.",
+		"command_added":           "Command {0} added. Change it with \"{1}\"",
+		"main_command_edit":       "The main command is changed with !setplatform, !setusername, !setformat and !setcooldown",
+		"command_not_found":       "The command {0} does not exist.",
+		"too_many_aliases":        "A command can have at most {0} aliases.",
+		"command_edited":          "Command {0} updated",
+		"main_command_delete":     "The main command can not be deleted, rename it with !setcmd",
+		"command_delete_error":    "There was an error deleting the command",
+		"command_deleted":         "Command {0} deleted",
+		"commands":                "Commands: {0}",
+		"delegate_error":          "There was an error adding the delegate",
+		"delegate_exists":         "{0} can already manage the bot",
+		"delegate_added":          "{0} can now manage the bot in your channel",
+		"undelegate_error":        "There was an error removing the delegate",
+		"delegate_not_found":      "{0} is not a delegate",
+		"delegate_removed":        "{0} can no longer manage the bot in your channel",
+		"no_delegates":            "No delegates. Add one with \"!delegate username\"",
+		"delegates":               "Delegates: {0}",
+		"twitch_user_not_found":   "Twitch user {0} does not exist",
+		"no_user_lookup":          "Twitch users can not be looked up on this bot",
+		"help.help":               "Lists the commands you can use or explains a single command",
+		"help.join":               "Adds the bot to your channel",
+		"help.leave":              "Removes the bot from your channel",
+		"help.set":                "Sets the platform and username of the player",
+		"help.setplatform":        "Sets the platform of the player (epic, steam, ps, xbox)",
+		"help.setusername":        "Sets the username of the player",
+		"help.setformat":          "Sets the format of the rank message",
+		"help.previewformat":      "Renders a format with the current ranks without saving it",
+		"help.setcmd":             "Renames the main rank command",
+		"help.setcooldown":        "Sets the channel wide cooldown of the main rank command",
+		"help.setusercooldown":    "Sets the cooldown per chatter, 0 disables it",
+		"help.setcooldownexempt":  "Lets the broadcaster, mods and VIPs ignore cooldowns",
+		"help.setcooldownwhisper": "Whispers the remaining cooldown to chatters",
+		"help.setlookup":          "Allows viewers to look up other players with \"!command platform username\"",
+		"help.setlookupcooldown":  "Sets the channel wide cooldown of player lookups",
+		"help.setprefix":          "Sets the prefix of the commands in your channel",
+		"help.setmentiononly":     "Only responds to rank commands addressed to the bot",
+		"help.setliveonly":        "Only responds to rank commands while the stream is live",
+		"help.setlang":            "Sets the language of the bot in your channel",
+		"help.addcmd":             "Adds another rank command, optionally for a different player",
+		"help.editcmd":            "Changes a setting of an additional rank command",
+		"help.delcmd":             "Deletes an additional rank command",
+		"help.listcmds":           "Lists the rank commands of the channel",
+		"help.resetsession":       "Starts a new session for the session MMR tokens",
+		"help.delegate":           "Allows a user to manage the bot in your channel",
+		"help.undelegate":         "Revokes the permissions of a delegate",
+		"help.delegates":          "Lists the delegates of your channel",
+
+		"rank_announce_on":               "Rank changes will now be announced while you are live",
+		"rank_announce_off":              "Rank change announcements disabled",
+		"rank_announce_interval_invalid": "The interval must be between {0} and {1} seconds",
+		"rank_announce_interval_set":     "Ranks are now checked every {0} seconds while you are live",
+		"rank_announce_format_too_long":  "The announcement can be at most {0} characters long",
+		"rank_announce_format_set":       "Rank announcement updated",
+		"rank_announce_format_reset":     "Rank announcement reset to the default",
+		"rank_up_announcement":           "GG! Now {0} Div {1} in {2}",
+		"rank_down_announcement":         "Dropped to {0} Div {1} in {2}",
+		"help.setrankannounce":           "Announces rank changes in chat while the stream is live",
+		"help.setrankannounceinterval":   "Sets how often the rank is checked for announcements",
+		"help.setrankannounceformat":     "Sets the rank announcement, placeholders: $(rank) $(div) $(playlist) $(mmr) $(oldrank) $(olddiv) $(oldmmr) $(direction)",
+	},
+	"de": {
+		"not_joined":              "Der Bot ist nicht in diesem Kanal",
+		"already_joined":          "Der Bot ist deinem Kanal bereits beigetreten.",
+		"join_error":              "Fehler beim Beitreten zu Kanal {0}",
+		"joined":                  "Der Bot ist deinem Kanal jetzt beigetreten!",
+		"leave_error":             "Fehler beim Verlassen von Kanal {0}",
+		"leaving":                 "Verlasse Kanal {0}",
+		"not_joined_channel":      "Der Bot war nicht in Kanal {0}",
+		"syntax":                  "Syntax: \"{0}\"",
+		"unknown_command":         "Unbekannter Befehl {0}",
+		"help_list":               "Befehle: {0}. Details mit \"!help befehl\"",
+		"valid_platforms":         "Gültige Plattformen: epic, steam, ps, xbox",
+		"username_too_long":       "Dieser Benutzername ist zu lang.",
+		"invalid_format":          "Ungültiges Format. {0}",
+		"update_error":            "Beim Speichern der Einstellungen ist ein Fehler aufgetreten",
+		"list_error":              "Beim Laden der Liste ist ein Fehler aufgetreten",
+		"setup_first":             "Bitte lege zuerst Plattform und Benutzernamen fest",
+		"player_not_found":        "Spieler {0} wurde auf Plattform {1} nicht gefunden",
+		"rank_error":              "Beim Abrufen des Rangs von Spieler {0} auf Plattform {1} ist ein Fehler aufgetreten",
+		"setup_incomplete":        "Bitte schließe die Einrichtung mit \"@{0} !set plattform benutzername\" ab",
+		"setup_platform":          "Bitte lege deine Plattform mit \"@{0} !setplatform plattform\" fest",
+		"setup_username":          "Bitte lege deinen Benutzernamen mit \"@{0} !setusername benutzername\" fest",
+		"platform_username_set":   "Plattform und Benutzername gespeichert",
+		"platform_set":            "Plattform gespeichert",
+		"username_set":            "Benutzername gespeichert",
+		"format_set":              "Format gespeichert",
+		"preview":                 "Vorschau: {0}",
+		"preview_error":           "Beim Erstellen der Vorschau ist ein Fehler aufgetreten",
+		"command_too_long":        "Dieser Befehl ist zu lang.",
+		"command_exists":          "Der Befehl {0} existiert bereits.",
+		"command_set":             "Befehl in {0} geändert",
+		"cooldown_invalid":        "Der Cooldown muss eine ganze Zahl von mindestens {0} sein.",
+		"cooldown_set":            "Cooldown auf {0} Sekunden gesetzt",
+		"lookup_on":               "Zuschauer können jetzt mit \"!befehl plattform benutzername\" nach Spielern suchen",
+		"lookup_off":              "Spielersuche deaktiviert",
+		"lookup_cooldown_set":     "Cooldown der Spielersuche auf {0} Sekunden gesetzt",
+		"user_cooldown_invalid":   "Der Cooldown pro Zuschauer muss eine ganze Zahl zwischen 0 und 86400 sein.",
+		"user_cooldown_off":       "Cooldown pro Zuschauer deaktiviert",
+		"user_cooldown_set":       "Cooldown pro Zuschauer auf {0} Sekunden gesetzt",
+		"cooldown_exempt_on":      "Broadcaster, Mods und VIPs ignorieren jetzt Cooldowns",
+		"cooldown_exempt_off":     "Cooldowns gelten jetzt für alle",
+		"cooldown_whisper_on":     "Zuschauer bekommen die verbleibende Cooldown-Zeit jetzt zugeflüstert",
+		"cooldown_whisper_off":    "Cooldown-Flüstern deaktiviert",
+		"no_whispers":             "Flüstern ist bei diesem Bot nicht verfügbar",
+		"cooldown_whisper":        "{0} in #{1} hat noch {2} Sekunden Cooldown",
+		"prefix_invalid":          "Das Präfix darf höchstens 3 Zeichen lang sein, keine Buchstaben, Ziffern oder \"@\" enthalten und nicht mit \"/\" oder \".\" beginnen",
+		"prefix_set":              "Präfix in {0} geändert",
+		"mention_only_on":         "Rang-Befehle reagieren jetzt nur noch auf \"@{0} befehl\"",
+		"mention_only_off":        "Rang-Befehle reagieren wieder auf das Präfix",
+		"live_only_on":            "Rang-Befehle reagieren jetzt nur noch, während du live bist",
+		"live_only_off":           "Rang-Befehle reagieren jetzt auch, während du offline bist",
+		"no_stream_status":        "Der Stream-Status ist bei diesem Bot nicht verfügbar",
+		"session_reset":           "Session-MMR zurückgesetzt",
+		"session_reset_error":     "Beim Zurücksetzen der Session ist ein Fehler aufgetreten",
+		"language_invalid":        "Unterstützte Sprachen: {0}",
+		"language_set":            "Sprache auf Deutsch gestellt",
+		"command_name_invalid":    "Befehlsnamen dürfen höchstens 50 Zeichen lang sein und keine Leerzeichen enthalten.",
+		"command_add_error":       "Beim Hinzufügen des Befehls ist ein Fehler aufgetreten",
+		"too_many_commands":       "Ein Kanal kann höchstens {0} zusätzliche Befehle haben.",
+		"command_added":           "Befehl {0} hinzugefügt. Ändere ihn mit \"{1}\"",
+		"main_command_edit":       "Der Hauptbefehl wird mit !setplatform, !setusername, !setformat und !setcooldown geändert",
+		"command_not_found":       "Der Befehl {0} existiert nicht.",
+		"too_many_aliases":        "Ein Befehl kann höchstens {0} Aliase haben.",
+		"command_edited":          "Befehl {0} gespeichert",
+		"main_command_delete":     "Der Hauptbefehl kann nicht gelöscht werden, benenne ihn mit !setcmd um",
+		"command_delete_error":    "Beim Löschen des Befehls ist ein Fehler aufgetreten",
+		"command_deleted":         "Befehl {0} gelöscht",
+		"commands":                "Befehle: {0}",
+		"delegate_error":          "Beim Hinzufügen des Verwalters ist ein Fehler aufgetreten",
+		"delegate_exists":         "{0} kann den Bot bereits verwalten",
+		"delegate_added":          "{0} kann den Bot jetzt in deinem Kanal verwalten",
+		"undelegate_error":        "Beim Entfernen des Verwalters ist ein Fehler aufgetreten",
+		"delegate_not_found":      "{0} ist kein Verwalter",
+		"delegate_removed":        "{0} kann den Bot nicht mehr in deinem Kanal verwalten",
+		"no_delegates":            "Keine Verwalter. Füge einen mit \"!delegate benutzername\" hinzu",
+		"delegates":               "Verwalter: {0}",
+		"twitch_user_not_found":   "Twitch-Nutzer {0} existiert nicht",
+		"no_user_lookup":          "Twitch-Nutzer können bei diesem Bot nicht gesucht werden",
+		"help.help":               "Listet die Befehle auf, die du nutzen kannst, oder erklärt einen Befehl",
+		"help.join":               "Fügt den Bot zu deinem Kanal hinzu",
+		"help.leave":              "Entfernt den Bot aus deinem Kanal",
+		"help.set":                "Legt Plattform und Benutzernamen des Spielers fest",
+		"help.setplatform":        "Legt die Plattform des Spielers fest (epic, steam, ps, xbox)",
+		"help.setusername":        "Legt den Benutzernamen des Spielers fest",
+		"help.setformat":          "Legt das Format der Rang-Nachricht fest",
+		"help.previewformat":      "Zeigt ein Format mit den aktuellen Rängen an, ohne es zu speichern",
+		"help.setcmd":             "Benennt den Haupt-Rangbefehl um",
+		"help.setcooldown":        "Legt den kanalweiten Cooldown des Haupt-Rangbefehls fest",
+		"help.setusercooldown":    "Legt den Cooldown pro Zuschauer fest, 0 deaktiviert ihn",
+		"help.setcooldownexempt":  "Lässt Broadcaster, Mods und VIPs Cooldowns ignorieren",
+		"help.setcooldownwhisper": "Flüstert Zuschauern die verbleibende Cooldown-Zeit zu",
+		"help.setlookup":          "Erlaubt Zuschauern die Suche nach anderen Spielern mit \"!befehl plattform benutzername\"",
+		"help.setlookupcooldown":  "Legt den kanalweiten Cooldown der Spielersuche fest",
+		"help.setprefix":          "Legt das Präfix der Befehle in deinem Kanal fest",
+		"help.setmentiononly":     "Reagiert nur auf Rang-Befehle, die an den Bot gerichtet sind",
+		"help.setliveonly":        "Reagiert nur auf Rang-Befehle, während der Stream live ist",
+		"help.setlang":            "Legt die Sprache des Bots in deinem Kanal fest",
+		"help.addcmd":             "Fügt einen weiteren Rang-Befehl hinzu, optional für einen anderen Spieler",
+		"help.editcmd":            "Ändert eine Einstellung eines zusätzlichen Rang-Befehls",
+		"help.delcmd":             "Löscht einen zusätzlichen Rang-Befehl",
+		"help.listcmds":           "Listet die Rang-Befehle des Kanals auf",
+		"help.resetsession":       "Startet eine neue Session für die Session-MMR-Tokens",
+		"help.delegate":           "Erlaubt einem Benutzer, den Bot in deinem Kanal zu verwalten",
+		"help.undelegate":         "Entzieht einem Verwalter die Rechte",
+		"help.delegates":          "Listet die Verwalter deines Kanals auf",
+
+		"rank_announce_on":               "Rang-Änderungen werden jetzt angekündigt, während du live bist",
+		"rank_announce_off":              "Ankündigungen von Rang-Änderungen deaktiviert",
+		"rank_announce_interval_invalid": "Das Intervall muss zwischen {0} und {1} Sekunden liegen",
+		"rank_announce_interval_set":     "Ränge werden jetzt alle {0} Sekunden geprüft, während du live bist",
+		"rank_announce_format_too_long":  "Die Ankündigung darf höchstens {0} Zeichen lang sein",
+		"rank_announce_format_set":       "Rang-Ankündigung aktualisiert",
+		"rank_announce_format_reset":     "Rang-Ankündigung auf den Standard zurückgesetzt",
+		"rank_up_announcement":           "GG! Jetzt {0} Div {1} in {2}",
+		"rank_down_announcement":         "Abgestiegen auf {0} Div {1} in {2}",
+		"help.setrankannounce":           "Kündigt Rang-Änderungen im Chat an, während der Stream live ist",
+		"help.setrankannounceinterval":   "Legt fest, wie oft der Rang für Ankündigungen geprüft wird",
+		"help.setrankannounceformat":     "Legt die Rang-Ankündigung fest, Platzhalter: $(rank) $(div) $(playlist) $(mmr) $(oldrank) $(olddiv) $(oldmmr) $(direction)",
+	},
+	"es": {
+		"not_joined":              "El bot no está en este canal",
+		"already_joined":          "El bot ya está en tu canal.",
+		"join_error":              "Error al unirse al canal {0}",
+		"joined":                  "¡El bot se ha unido a tu canal!",
+		"leave_error":             "Error al salir del canal {0}",
+		"leaving":                 "Saliendo del canal {0}",
+		"not_joined_channel":      "El bot no estaba en el canal {0}",
+		"syntax":                  "Sintaxis: \"{0}\"",
+		"unknown_command":         "Comando desconocido {0}",
+		"help_list":               "Comandos: {0}. Detalles con \"!help comando\"",
+		"valid_platforms":         "Plataformas válidas: epic, steam, ps, xbox",
+		"username_too_long":       "Este nombre de usuario es demasiado largo.",
+		"invalid_format":          "Formato no válido. {0}",
+		"update_error":            "Hubo un error al guardar la configuración",
+		"list_error":              "Hubo un error al cargar la lista",
+		"setup_first":             "Primero configura una plataforma y un nombre de usuario",
+		"player_not_found":        "No se encontró al jugador {0} en la plataforma {1}",
+		"rank_error":              "Hubo un error al obtener el rango del jugador {0} en la plataforma {1}",
+		"setup_incomplete":        "Completa la configuración con \"@{0} !set plataforma usuario\"",
+		"setup_platform":          "Configura tu plataforma con \"@{0} !setplatform plataforma\"",
+		"setup_username":          "Configura tu nombre de usuario con \"@{0} !setusername usuario\"",
+		"platform_username_set":   "Plataforma y nombre de usuario actualizados",
+		"platform_set":            "Plataforma actualizada",
+		"username_set":            "Nombre de usuario actualizado",
+		"format_set":              "Formato actualizado",
+		"preview":                 "Vista previa: {0}",
+		"preview_error":           "Hubo un error al generar la vista previa",
+		"command_too_long":        "Este comando es demasiado largo.",
+		"command_exists":          "El comando {0} ya existe.",
+		"command_set":             "Comando cambiado a {0}",
+		"cooldown_invalid":        "El cooldown debe ser un número entero de al menos {0}.",
+		"cooldown_set":            "Cooldown actualizado a {0} segundos",
+		"lookup_on":               "Los espectadores ahora pueden buscar jugadores con \"!comando plataforma usuario\"",
+		"lookup_off":              "Búsqueda de jugadores desactivada",
+		"lookup_cooldown_set":     "Cooldown de búsqueda actualizado a {0} segundos",
+		"user_cooldown_invalid":   "El cooldown por espectador debe ser un número entero entre 0 y 86400.",
+		"user_cooldown_off":       "Cooldown por espectador desactivado",
+		"user_cooldown_set":       "Cooldown por espectador actualizado a {0} segundos",
+		"cooldown_exempt_on":      "El streamer, los mods y los VIPs ahora ignoran los cooldowns",
+		"cooldown_exempt_off":     "Los cooldowns ahora se aplican a todos",
+		"cooldown_whisper_on":     "Los espectadores en cooldown recibirán el tiempo restante por susurro",
+		"cooldown_whisper_off":    "Susurros de cooldown desactivados",
+		"no_whispers":             "Los susurros no están disponibles en este bot",
+		"cooldown_whisper":        "{0} en #{1} está en cooldown durante {2} segundos más",
+		"prefix_invalid":          "El prefijo puede tener como máximo 3 caracteres, sin letras, dígitos ni \"@\", y no puede empezar con \"/\" o \".\"",
+		"prefix_set":              "Prefijo cambiado a {0}",
+		"mention_only_on":         "Los comandos de rango ahora solo responden a \"@{0} comando\"",
+		"mention_only_off":        "Los comandos de rango vuelven a responder al prefijo",
+		"live_only_on":            "Los comandos de rango ahora solo responden mientras estás en directo",
+		"live_only_off":           "Los comandos de rango ahora también responden cuando no estás en directo",
+		"no_stream_status":        "El estado del directo no está disponible en este bot",
+		"session_reset":           "MMR de la sesión reiniciado",
+		"session_reset_error":     "Hubo un error al reiniciar la sesión",
+		"language_invalid":        "Idiomas disponibles: {0}",
+		"language_set":            "Idioma cambiado a español",
+		"command_name_invalid":    "Los nombres de comando pueden tener como máximo 50 caracteres y no pueden contener espacios.",
+		"command_add_error":       "Hubo un error al añadir el comando",
+		"too_many_commands":       "Un canal puede tener como máximo {0} comandos adicionales.",
+		"command_added":           "Comando {0} añadido. Cámbialo con \"{1}\"",
+		"main_command_edit":       "El comando principal se cambia con !setplatform, !setusername, !setformat y !setcooldown",
+		"command_not_found":       "El comando {0} no existe.",
+		"too_many_aliases":        "Un comando puede tener como máximo {0} alias.",
+		"command_edited":          "Comando {0} actualizado",
+		"main_command_delete":     "El comando principal no se puede eliminar, cámbiale el nombre con !setcmd",
+		"command_delete_error":    "Hubo un error al eliminar el comando",
+		"command_deleted":         "Comando {0} eliminado",
+		"commands":                "Comandos: {0}",
+		"delegate_error":          "Hubo un error al añadir el delegado",
+		"delegate_exists":         "{0} ya puede gestionar el bot",
+		"delegate_added":          "{0} ahora puede gestionar el bot en tu canal",
+		"undelegate_error":        "Hubo un error al quitar el delegado",
+		"delegate_not_found":      "{0} no es un delegado",
+		"delegate_removed":        "{0} ya no puede gestionar el bot en tu canal",
+		"no_delegates":            "No hay delegados. Añade uno con \"!delegate usuario\"",
+		"delegates":               "Delegados: {0}",
+		"twitch_user_not_found":   "El usuario de Twitch {0} no existe",
+		"no_user_lookup":          "Este bot no puede buscar usuarios de Twitch",
+		"help.help":               "Muestra los comandos que puedes usar o explica un comando",
+		"help.join":               "Añade el bot a tu canal",
+		"help.leave":              "Quita el bot de tu canal",
+		"help.set":                "Configura la plataforma y el nombre de usuario del jugador",
+		"help.setplatform":        "Configura la plataforma del jugador (epic, steam, ps, xbox)",
+		"help.setusername":        "Configura el nombre de usuario del jugador",
+		"help.setformat":          "Configura el formato del mensaje de rango",
+		"help.previewformat":      "Muestra un formato con los rangos actuales sin guardarlo",
+		"help.setcmd":             "Cambia el nombre del comando de rango principal",
+		"help.setcooldown":        "Configura el cooldown del canal para el comando de rango principal",
+		"help.setusercooldown":    "Configura el cooldown por espectador, 0 lo desactiva",
+		"help.setcooldownexempt":  "Permite que el streamer, los mods y los VIPs ignoren los cooldowns",
+		"help.setcooldownwhisper": "Envía por susurro el cooldown restante a los espectadores",
+		"help.setlookup":          "Permite a los espectadores buscar otros jugadores con \"!comando plataforma usuario\"",
+		"help.setlookupcooldown":  "Configura el cooldown del canal para la búsqueda de jugadores",
+		"help.setprefix":          "Configura el prefijo de los comandos en tu canal",
+		"help.setmentiononly":     "Solo responde a comandos de rango dirigidos al bot",
+		"help.setliveonly":        "Solo responde a comandos de rango mientras el directo está activo",
+		"help.setlang":            "Configura el idioma del bot en tu canal",
+		"help.addcmd":             "Añade otro comando de rango, opcionalmente para otro jugador",
+		"help.editcmd":            "Cambia una opción de un comando de rango adicional",
+		"help.delcmd":             "Elimina un comando de rango adicional",
+		"help.listcmds":           "Muestra los comandos de rango del canal",
+		"help.resetsession":       "Inicia una nueva sesión para los tokens de MMR de sesión",
+		"help.delegate":           "Permite a un usuario gestionar el bot en tu canal",
+		"help.undelegate":         "Retira los permisos de un delegado",
+		"help.delegates":          "Muestra los delegados de tu canal",
+
+		"rank_announce_on":               "Los cambios de rango se anunciarán mientras estés en directo",
+		"rank_announce_off":              "Anuncios de cambios de rango desactivados",
+		"rank_announce_interval_invalid": "El intervalo debe estar entre {0} y {1} segundos",
+		"rank_announce_interval_set":     "Los rangos se comprueban cada {0} segundos mientras estás en directo",
+		"rank_announce_format_too_long":  "El anuncio puede tener como máximo {0} caracteres",
+		"rank_announce_format_set":       "Anuncio de rango actualizado",
+		"rank_announce_format_reset":     "Anuncio de rango restablecido al predeterminado",
+		"rank_up_announcement":           "¡GG! Ahora {0} Div {1} en {2}",
+		"rank_down_announcement":         "Bajó a {0} Div {1} en {2}",
+		"help.setrankannounce":           "Anuncia los cambios de rango en el chat mientras el directo está activo",
+		"help.setrankannounceinterval":   "Define cada cuánto se comprueba el rango para los anuncios",
+		"help.setrankannounceformat":     "Define el anuncio de rango, variables: $(rank) $(div) $(playlist) $(mmr) $(oldrank) $(olddiv) $(oldmmr) $(direction)",
+	},
+	"fr": {
+		"not_joined":              "Le bot n'est pas sur cette chaîne",
+		"already_joined":          "Le bot a déjà rejoint ta chaîne.",
+		"join_error":              "Erreur en rejoignant la chaîne {0}",
+		"joined":                  "Le bot a rejoint ta chaîne !",
+		"leave_error":             "Erreur en quittant la chaîne {0}",
+		"leaving":                 "Le bot quitte la chaîne {0}",
+		"not_joined_channel":      "Le bot n'était pas sur la chaîne {0}",
+		"syntax":                  "Syntaxe : \"{0}\"",
+		"unknown_command":         "Commande inconnue {0}",
+		"help_list":               "Commandes : {0}. Détails avec \"!help commande\"",
+		"valid_platforms":         "Plateformes valides : epic, steam, ps, xbox",
+		"username_too_long":       "Ce nom d'utilisateur est trop long.",
+		"invalid_format":          "Format invalide. {0}",
+		"update_error":            "Une erreur est survenue lors de l'enregistrement des paramètres",
+		"list_error":              "Une erreur est survenue lors du chargement de la liste",
+		"setup_first":             "Configure d'abord une plateforme et un nom d'utilisateur",
+		"player_not_found":        "Le joueur {0} est introuvable sur la plateforme {1}",
+		"rank_error":              "Une erreur est survenue lors de la récupération du rang du joueur {0} sur la plateforme {1}",
+		"setup_incomplete":        "Termine la configuration avec \"@{0} !set plateforme pseudo\"",
+		"setup_platform":          "Configure ta plateforme avec \"@{0} !setplatform plateforme\"",
+		"setup_username":          "Configure ton nom d'utilisateur avec \"@{0} !setusername pseudo\"",
+		"platform_username_set":   "Plateforme et nom d'utilisateur mis à jour",
+		"platform_set":            "Plateforme mise à jour",
+		"username_set":            "Nom d'utilisateur mis à jour",
+		"format_set":              "Format mis à jour",
+		"preview":                 "Aperçu : {0}",
+		"preview_error":           "Une erreur est survenue lors de la création de l'aperçu",
+		"command_too_long":        "Cette commande est trop longue.",
+		"command_exists":          "La commande {0} existe déjà.",
+		"command_set":             "Commande changée en {0}",
+		"cooldown_invalid":        "Le cooldown doit être un nombre entier d'au moins {0}.",
+		"cooldown_set":            "Cooldown mis à {0} secondes",
+		"lookup_on":               "Les viewers peuvent maintenant chercher des joueurs avec \"!commande plateforme pseudo\"",
+		"lookup_off":              "Recherche de joueurs désactivée",
+		"lookup_cooldown_set":     "Cooldown de recherche mis à {0} secondes",
+		"user_cooldown_invalid":   "Le cooldown par viewer doit être un nombre entier entre 0 et 86400.",
+		"user_cooldown_off":       "Cooldown par viewer désactivé",
+		"user_cooldown_set":       "Cooldown par viewer mis à {0} secondes",
+		"cooldown_exempt_on":      "Le streamer, les modos et les VIP ignorent maintenant les cooldowns",
+		"cooldown_exempt_off":     "Les cooldowns s'appliquent maintenant à tout le monde",
+		"cooldown_whisper_on":     "Les viewers en cooldown recevront le temps restant en chuchotement",
+		"cooldown_whisper_off":    "Chuchotements de cooldown désactivés",
+		"no_whispers":             "Les chuchotements ne sont pas disponibles sur ce bot",
+		"cooldown_whisper":        "{0} sur #{1} est en cooldown pendant encore {2} secondes",
+		"prefix_invalid":          "Le préfixe peut contenir au maximum 3 caractères, sans lettres, chiffres ni \"@\", et ne peut pas commencer par \"/\" ou \".\"",
+		"prefix_set":              "Préfixe changé en {0}",
+		"mention_only_on":         "Les commandes de rang ne répondent plus qu'à \"@{0} commande\"",
+		"mention_only_off":        "Les commandes de rang répondent de nouveau au préfixe",
+		"live_only_on":            "Les commandes de rang ne répondent plus que pendant ton live",
+		"live_only_off":           "Les commandes de rang répondent aussi hors live",
+		"no_stream_status":        "Le statut du stream n'est pas disponible sur ce bot",
+		"session_reset":           "MMR de session réinitialisé",
+		"session_reset_error":     "Une erreur est survenue lors de la réinitialisation de la session",
+		"language_invalid":        "Langues disponibles : {0}",
+		"language_set":            "Langue changée en français",
+		"command_name_invalid":    "Les noms de commande peuvent contenir au maximum 50 caractères et aucun espace.",
+		"command_add_error":       "Une erreur est survenue lors de l'ajout de la commande",
+		"too_many_commands":       "Une chaîne peut avoir au maximum {0} commandes supplémentaires.",
+		"command_added":           "Commande {0} ajoutée. Modifie-la avec \"{1}\"",
+		"main_command_edit":       "La commande principale se modifie avec !setplatform, !setusername, !setformat et !setcooldown",
+		"command_not_found":       "La commande {0} n'existe pas.",
+		"too_many_aliases":        "Une commande peut avoir au maximum {0} alias.",
+		"command_edited":          "Commande {0} mise à jour",
+		"main_command_delete":     "La commande principale ne peut pas être supprimée, renomme-la avec !setcmd",
+		"command_delete_error":    "Une erreur est survenue lors de la suppression de la commande",
+		"command_deleted":         "Commande {0} supprimée",
+		"commands":                "Commandes : {0}",
+		"delegate_error":          "Une erreur est survenue lors de l'ajout du délégué",
+		"delegate_exists":         "{0} peut déjà gérer le bot",
+		"delegate_added":          "{0} peut maintenant gérer le bot sur ta chaîne",
+		"undelegate_error":        "Une erreur est survenue lors du retrait du délégué",
+		"delegate_not_found":      "{0} n'est pas un délégué",
+		"delegate_removed":        "{0} ne peut plus gérer le bot sur ta chaîne",
+		"no_delegates":            "Aucun délégué. Ajoutes-en un avec \"!delegate pseudo\"",
+		"delegates":               "Délégués : {0}",
+		"twitch_user_not_found":   "L'utilisateur Twitch {0} n'existe pas",
+		"no_user_lookup":          "Ce bot ne peut pas rechercher d'utilisateurs Twitch",
+		"help.help":               "Liste les commandes que tu peux utiliser ou explique une commande",
+		"help.join":               "Ajoute le bot à ta chaîne",
+		"help.leave":              "Retire le bot de ta chaîne",
+		"help.set":                "Définit la plateforme et le nom d'utilisateur du joueur",
+		"help.setplatform":        "Définit la plateforme du joueur (epic, steam, ps, xbox)",
+		"help.setusername":        "Définit le nom d'utilisateur du joueur",
+		"help.setformat":          "Définit le format du message de rang",
+		"help.previewformat":      "Affiche un format avec les rangs actuels sans l'enregistrer",
+		"help.setcmd":             "Renomme la commande de rang principale",
+		"help.setcooldown":        "Définit le cooldown de la chaîne pour la commande de rang principale",
+		"help.setusercooldown":    "Définit le cooldown par viewer, 0 le désactive",
+		"help.setcooldownexempt":  "Permet au streamer, aux modos et aux VIP d'ignorer les cooldowns",
+		"help.setcooldownwhisper": "Chuchote le cooldown restant aux viewers",
+		"help.setlookup":          "Permet aux viewers de chercher d'autres joueurs avec \"!commande plateforme pseudo\"",
+		"help.setlookupcooldown":  "Définit le cooldown de la chaîne pour la recherche de joueurs",
+		"help.setprefix":          "Définit le préfixe des commandes sur ta chaîne",
+		"help.setmentiononly":     "Ne répond qu'aux commandes de rang adressées au bot",
+		"help.setliveonly":        "Ne répond aux commandes de rang que pendant le live",
+		"help.setlang":            "Définit la langue du bot sur ta chaîne",
+		"help.addcmd":             "Ajoute une autre commande de rang, éventuellement pour un autre joueur",
+		"help.editcmd":            "Modifie un paramètre d'une commande de rang supplémentaire",
+		"help.delcmd":             "Supprime une commande de rang supplémentaire",
+		"help.listcmds":           "Liste les commandes de rang de la chaîne",
+		"help.resetsession":       "Démarre une nouvelle session pour les tokens de MMR de session",
+		"help.delegate":           "Permet à un utilisateur de gérer le bot sur ta chaîne",
+		"help.undelegate":         "Retire les droits d'un délégué",
+		"help.delegates":          "Liste les délégués de ta chaîne",
+
+		"rank_announce_on":               "Les changements de rang seront annoncés pendant ton live",
+		"rank_announce_off":              "Annonces de changement de rang désactivées",
+		"rank_announce_interval_invalid": "L'intervalle doit être compris entre {0} et {1} secondes",
+		"rank_announce_interval_set":     "Les rangs sont vérifiés toutes les {0} secondes pendant ton live",
+		"rank_announce_format_too_long":  "L'annonce peut contenir au maximum {0} caractères",
+		"rank_announce_format_set":       "Annonce de rang mise à jour",
+		"rank_announce_format_reset":     "Annonce de rang réinitialisée",
+		"rank_up_announcement":           "GG ! Maintenant {0} Div {1} en {2}",
+		"rank_down_announcement":         "Descendu à {0} Div {1} en {2}",
+		"help.setrankannounce":           "Annonce les changements de rang dans le chat pendant le live",
+		"help.setrankannounceinterval":   "Définit la fréquence de vérification du rang pour les annonces",
+		"help.setrankannounceformat":     "Définit l'annonce de rang, variables : $(rank) $(div) $(playlist) $(mmr) $(oldrank) $(olddiv) $(oldmmr) $(direction)",
+	},
+}
+
+func isSupportedLanguage(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+func supportedLanguages() []string {
+	langs := make([]string, 0, len(catalog))
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// translate returns the message in the given language, falling back to English and finally to the key itself.
+func translate(lang string, key string, args ...string) string {
+	msg, ok := catalog[lang][key]
+	if !ok {
+		msg, ok = catalog[defaultLanguage][key]
+		if !ok {
+			msg = key
+		}
+	}
+	replacements := make([]string, 0, len(args)*2)
+	for i, arg := range args {
+		replacements = append(replacements, "{"+strconv.Itoa(i)+"}", arg)
+	}
+	return strings.NewReplacer(replacements...).Replace(msg)
+}
+
+// languageOf returns the language of the channel the message was sent in.
+// In the bot's own channel the language of the chatter's channel is used.
+func languageOf(message *twitch.PrivateMessage) string {
+	channel, _, err := getCachedChannel(channelOwnerId(message))
+	if err != nil || !isSupportedLanguage(channel.Language) {
+		return defaultLanguage
+	}
+	return channel.Language
+}
+
+// reply sends a translated message addressed to the chatter.
+func reply(message *twitch.PrivateMessage, key string, args ...string) {
+	outbound.Say(message.Channel, "@"+message.User.Name+" "+translate(languageOf(message), key, args...))
+}
+
+// announce sends a translated message to the channel without addressing the chatter.
+func announce(message *twitch.PrivateMessage, key string, args ...string) {
+	outbound.Say(message.Channel, translate(languageOf(message), key, args...))
+}
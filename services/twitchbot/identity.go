@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+// The bot stops receiving messages of a channel under its old name, so renames outside of the bot channel are only
+// noticed by the periodic sync.
+const (
+	loginSyncInterval = time.Minute * 30
+	helixUsersPerPage = 100
+)
+
+func loginChangeKey(twitchUserId string) string {
+	return channelCacheKey(twitchUserId) + ":rename"
+}
+
+// checkLoginChange compares the login a channel was seen with to the stored one and handles a rename.
+func checkLoginChange(client *twitch.Client, channel *CachedChannel, login string) {
+	if channel.TwitchLogin == "" || channel.TwitchLogin == login {
+		return
+	}
+	handleLoginChange(client, channel.TwitchUserId, channel.TwitchLogin, login)
+}
+
+// handleLoginChange stores the new login of a renamed channel and moves the bot from the old to the new channel name.
+func handleLoginChange(client *twitch.Client, twitchUserId string, oldLogin string, newLogin string) {
+	// messages arriving in parallel would otherwise all trigger the rename
+	first, err := redisCache.SetIfAbsent(loginChangeKey(twitchUserId), newLogin, time.Minute)
+	if err != nil {
+		log.WithField("event", "login_change").Error(err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	wasChanged, err := botDb.UpdateTwitchLoginByTwitchUserId(twitchUserId, newLogin)
+	if err != nil {
+		log.WithField("event", "login_change_db_update").Error(err)
+		redisCache.Delete(loginChangeKey(twitchUserId))
+		return
+	}
+	if !wasChanged {
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+
+	log.WithField("event", "login_change").WithField("channel", newLogin).Info("Channel renamed from " + oldLogin)
+	client.Depart(oldLogin)
+	outbound.Forget(oldLogin)
+	client.Join(newLogin)
+}
+
+// syncChannelLogins looks up the current login of every joined channel and handles renames that happened
+// while the bot was not in the channel to notice them.
+func syncChannelLogins(client *twitch.Client) {
+	logins, err := botDb.GetTwitchLogins()
+	if err != nil {
+		log.WithField("event", "login_sync").Error(err)
+		return
+	}
+
+	ids := make([]string, 0, len(logins))
+	for id := range logins {
+		ids = append(ids, id)
+	}
+
+	renamed := 0
+	for start := 0; start < len(ids); start += helixUsersPerPage {
+		end := start + helixUsersPerPage
+		if end > len(ids) {
+			end = len(ids)
+		}
+		users, err := helix.GetUsers(ids[start:end], nil)
+		if err != nil {
+			log.WithField("event", "login_sync").Error(err)
+			return
+		}
+		for _, user := range users {
+			if oldLogin, ok := logins[user.Id]; ok && oldLogin != user.Login {
+				handleLoginChange(client, user.Id, oldLogin, user.Login)
+				renamed++
+			}
+		}
+	}
+	log.WithField("event", "login_sync").Info("Found " + strconv.Itoa(renamed) + " renamed channels")
+}
+
+func runChannelLoginSync(client *twitch.Client) {
+	ticker := time.NewTicker(loginSyncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		syncChannelLogins(client)
+	}
+}
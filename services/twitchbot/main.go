@@ -1,12 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"github.com/gempir/go-twitch-irc/v3"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/tkanos/gonfig"
 	"github.com/yannismate/yannismate-api/libs/cache"
+	"github.com/yannismate/yannismate-api/libs/rankformat"
 	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
 	"net/http"
 	"strconv"
@@ -17,6 +17,9 @@ import (
 var configuration Configuration
 var botDb *BotDb
 var redisCache cache.Cache
+var helix *HelixClient
+var outbound *Outbound
+var invalidationBus *cache.InvalidationBus
 
 func main() {
 	metricsServer := http.NewServeMux()
@@ -47,6 +50,30 @@ func main() {
 	client.SetJoinRateLimiter(twitch.CreateDefaultRateLimiter())
 
 	redisCache = cache.NewCache(configuration.CacheUrl)
+	invalidationBus = cache.NewInvalidationBus(&redisCache, "twitchbot")
+	listenForInvalidations()
+	registerCommands()
+	publishCommandNames()
+
+	if configuration.TwitchClientId != "" {
+		helix = NewHelixClient(configuration.TwitchClientId, configuration.TwitchClientSecret, strings.TrimPrefix(configuration.TwitchToken, "oauth:"), configuration.HelixUrl, configuration.TwitchAuthUrl)
+		resolveBotUserId()
+		registerStreamEventHandlers()
+		registerRankWatcher()
+		go runChannelLoginSync(client)
+		if isEventSubEnabled() {
+			startEventSubServer()
+			go syncEventSubSubscriptions()
+		}
+	} else {
+		log.WithField("event", "helix_disabled").Warn("No Twitch client id configured, whispers and stream status are unavailable")
+	}
+
+	outbound = NewOutbound(client, configuration.Outbound)
+	go outbound.Run()
+	client.OnUserStateMessage(func(message twitch.UserStateMessage) {
+		outbound.SetModerator(message.Channel, message.User.Badges["moderator"] > 0 || message.User.Badges["broadcaster"] > 0)
+	})
 
 	client.OnPrivateMessage(func(message twitch.PrivateMessage) {
 		go handleMessage(message, client)
@@ -58,6 +85,9 @@ func main() {
 		metricChannelsJoined.Set(0)
 		log.WithField("event", "irc_connected").Info("IRC connected")
 		go func() {
+			if helix != nil {
+				syncChannelLogins(client)
+			}
 			namesCursor := ""
 			for {
 				names, newNamesCursor, err := botDb.GetUserNames(namesCursor, 20)
@@ -81,7 +111,7 @@ func main() {
 				time.Sleep(time.Second * 15)
 			}
 		}()
-		client.Say(configuration.TwitchUsername, configuration.TwitchUsername+" online! MrDestructoid")
+		outbound.Say(configuration.TwitchUsername, configuration.TwitchUsername+" online! MrDestructoid")
 	})
 
 	err = client.Connect()
@@ -89,74 +119,34 @@ func main() {
 
 func handleMessage(message twitch.PrivateMessage, client *twitch.Client) {
 	metricMessagesReceived.Inc()
-	if message.Channel == strings.ToLower(configuration.TwitchUsername) {
-		switch strings.Split(strings.ToLower(message.Message), " ")[0] {
-		case "!join":
-			joinChannelCommand(&message, client)
-		case "!leave":
-			leaveChannelCommand(&message, client)
-		case "!set":
-			setCommand(&message, client)
-		case "!setplatform":
-			setPlatformCommand(&message, client)
-		case "!setusername":
-			setUsernameCommand(&message, client)
-		case "!setformat":
-			setFormatCommand(&message, client)
-		case "!setcmd":
-			setCmdCommand(&message, client)
-		case "!setcooldown":
-			setCooldownCommand(&message, client)
+	input, mentioned := stripMention(&message)
+	if isBotChannel(&message) {
+		if own, _, err := getCachedChannel(message.User.ID); err == nil {
+			checkLoginChange(client, own, message.User.Name)
 		}
-	} else if strings.HasPrefix(strings.ToLower(message.Message), "@"+strings.ToLower(configuration.TwitchUsername)) {
-		if message.Channel == message.User.Name {
-			msg := strings.TrimPrefix(strings.ToLower(message.Message), "@"+strings.ToLower(configuration.TwitchUsername)+" ")
-			switch strings.Split(msg, " ")[0] {
-			case "!leave":
-				leaveChannelCommand(&message, client)
-			case "!set":
-				setCommand(&message, client)
-			case "!setplatform":
-				setPlatformCommand(&message, client)
-			case "!setusername":
-				setUsernameCommand(&message, client)
-			case "!setformat":
-				setFormatCommand(&message, client)
-			case "!setcmd":
-				setCmdCommand(&message, client)
-			case "!setcooldown":
-				setCooldownCommand(&message, client)
-			}
-		} else {
-			isMod, ok := message.Tags["mod"]
-			if ok && isMod == "1" {
-				msg := strings.TrimPrefix(strings.ToLower(message.Message), "@"+strings.ToLower(configuration.TwitchUsername)+" ")
-				switch strings.Split(msg, " ")[0] {
-				case "!set":
-					setCommand(&message, client)
-				case "!setplatform":
-					setPlatformCommand(&message, client)
-				case "!setusername":
-					setUsernameCommand(&message, client)
-				case "!setformat":
-					setFormatCommand(&message, client)
-				case "!setcmd":
-					setCmdCommand(&message, client)
-				case "!setcooldown":
-					setCooldownCommand(&message, client)
-				}
-			}
-		}
-	} else if strings.HasPrefix(message.Message, "!") {
-		checkForRankCommand(&message, client)
+		dispatchCommand(&message, client, input, "!", false)
+		return
+	}
+	if !mentioned && !mayBeCommand(input) {
+		return
+	}
+
+	channel, fromCache, err := getCachedChannel(message.RoomID)
+	if err != nil {
+		log.WithField("event", "user_command_get_db").Warn(err)
+		return
 	}
+	if mentioned && dispatchCommand(&message, client, input, channel.Prefix, true) {
+		return
+	}
+	checkForRankCommand(&message, client, channel, fromCache, input, mentioned)
 }
 
-func joinChannelCommand(message *twitch.PrivateMessage, client *twitch.Client) {
+func joinChannelCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
 	log.WithField("event", "join_command").WithField("channel", message.Channel).Info("Executing join command")
 	_, err := botDb.GetBotUserByTwitchUserId(message.User.ID)
 	if err == nil {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot has already joined your channel.")
+		reply(message, "already_joined")
 		return
 	}
 	newUser := BotUser{
@@ -167,30 +157,33 @@ func joinChannelCommand(message *twitch.PrivateMessage, client *twitch.Client) {
 	}
 	err = botDb.InsertBotUser(newUser)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" Error joining channel "+message.User.Name)
+		reply(message, "join_error", message.User.Name)
 		log.WithField("event", "join_command").Error(err)
 		return
 	}
 	client.Join(message.User.Name)
 	metricChannelsJoined.Inc()
-	client.Say(message.Channel, "@"+message.User.Name+" The bot has now joined your channel!")
+	go subscribeStreamEvents(message.User.ID)
+	reply(message, "joined")
 }
 
-func leaveChannelCommand(message *twitch.PrivateMessage, client *twitch.Client) {
+func leaveChannelCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
 	log.WithField("event", "leave_command").WithField("channel", message.Channel).Info("Executing leave command")
 	wasDeleted, err := botDb.DeleteBotUserByTwitchUserId(message.User.ID)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" Error leaving channel "+message.User.Name)
+		reply(message, "leave_error", message.User.Name)
 		log.WithField("event", "leave_command").Error(err)
 		return
 	}
 	if wasDeleted {
-		redisCache.Delete("twitch:" + message.User.Name)
-		client.Say(message.Channel, "@"+message.User.Name+" Leaving channel "+message.User.Name)
+		invalidateChannelCache(message.User.ID)
+		reply(message, "leaving", message.User.Name)
 		client.Depart(message.User.Name)
+		outbound.Forget(message.User.Name)
+		go unsubscribeStreamEvents(message.User.ID)
 		metricChannelsJoined.Dec()
 	} else {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot was not joined to channel "+message.User.Name)
+		reply(message, "not_joined_channel", message.User.Name)
 	}
 }
 
@@ -201,334 +194,443 @@ var validPlatforms = map[string]bool{
 	trackernet.Xbox:  true,
 }
 
-func setCommand(message *twitch.PrivateMessage, client *twitch.Client) {
+func setCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
 	log.WithField("event", "set_command").WithField("channel", message.Channel).Info("Executing set command")
-	cmdContentArr := strings.SplitN(message.Message, "!set ", 2)
-	if len(cmdContentArr) != 2 {
-		client.Say(message.Channel, "@"+message.User.Name+" Syntax: \"!set platform username\"")
-		return
-	}
-	cmdContent := cmdContentArr[1]
-
-	contentParts := strings.SplitN(cmdContent, " ", 2)
-	if len(contentParts) != 2 {
-		client.Say(message.Channel, "@"+message.User.Name+" Syntax: \"!set platform username\"")
-		return
-	}
-
-	newPlatform := contentParts[0]
-	newUsername := contentParts[1]
+	newPlatform := strings.ToLower(args[0])
+	newUsername := args[1]
 
 	if !validPlatforms[newPlatform] {
-		client.Say(message.Channel, "@"+message.User.Name+" Valid platforms: epic, steam, ps, xbox")
+		reply(message, "valid_platforms")
 		return
 	}
 
-	var user string
-	if message.Channel == configuration.TwitchUsername {
-		user = message.User.Name
-	} else {
-		user = message.Channel
-	}
+	twitchUserId := channelOwnerId(message)
 
-	wasChanged, err := botDb.UpdateRlPlatformAndUsernameByTwitchLogin(user, newPlatform, newUsername)
+	wasChanged, err := botDb.UpdateRlPlatformAndUsernameByTwitchUserId(twitchUserId, newPlatform, newUsername)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" There was an error updating your settings")
+		reply(message, "update_error")
 		log.WithField("event", "set_command_db_update").Error(err)
 		return
 	}
 	if !wasChanged {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot is not joined")
+		reply(message, "not_joined")
 		return
 	}
-	redisCache.Delete("twitch:" + user)
-	client.Say(message.Channel, "@"+message.User.Name+" Platform and username updated")
+	invalidateChannelCache(twitchUserId)
+	reply(message, "platform_username_set")
 }
 
-func setPlatformCommand(message *twitch.PrivateMessage, client *twitch.Client) {
+func setPlatformCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
 	log.WithField("event", "setplatform_command").WithField("channel", message.Channel).Info("Executing setplatform command")
-	cmdContent := strings.SplitN(message.Message, "!setplatform ", 2)
-	if len(cmdContent) != 2 {
-		client.Say(message.Channel, "@"+message.User.Name+" Syntax: \"!setplatform platform\"")
-		return
-	}
-	newPlatform := cmdContent[1]
+	newPlatform := strings.ToLower(args[0])
 
 	if !validPlatforms[newPlatform] {
-		client.Say(message.Channel, "@"+message.User.Name+" Valid platforms: epic, steam, ps, xbox")
+		reply(message, "valid_platforms")
 		return
 	}
 
-	var user string
-	if message.Channel == configuration.TwitchUsername {
-		user = message.User.Name
-	} else {
-		user = message.Channel
-	}
+	twitchUserId := channelOwnerId(message)
 
-	wasChanged, err := botDb.UpdateRlPlatformByTwitchLogin(user, newPlatform)
+	wasChanged, err := botDb.UpdateRlPlatformByTwitchUserId(twitchUserId, newPlatform)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" There was an error updating the platform")
+		reply(message, "update_error")
 		log.WithField("event", "setplatform_command_db_update").Error(err)
 		return
 	}
 	if !wasChanged {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot is not joined")
+		reply(message, "not_joined")
 		return
 	}
-	redisCache.Delete("twitch:" + user)
-	client.Say(message.Channel, "@"+message.User.Name+" Platform updated")
+	invalidateChannelCache(twitchUserId)
+	reply(message, "platform_set")
 }
 
-func setUsernameCommand(message *twitch.PrivateMessage, client *twitch.Client) {
+func setUsernameCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
 	log.WithField("event", "setusername_command").WithField("channel", message.Channel).Info("Executing setusername command")
-	cmdContent := strings.SplitN(message.Message, "!setusername ", 2)
-	if len(cmdContent) != 2 {
-		client.Say(message.Channel, "@"+message.User.Name+" Syntax: \"!setusername username\"")
-		return
-	}
-	newUsername := cmdContent[1]
+	newUsername := args[0]
 
 	if len(newUsername) > 255 {
-		client.Say(message.Channel, "@"+message.User.Name+" This username is too long.")
+		reply(message, "username_too_long")
 		return
 	}
 
-	var user string
-	if message.Channel == configuration.TwitchUsername {
-		user = message.User.Name
-	} else {
-		user = message.Channel
-	}
+	twitchUserId := channelOwnerId(message)
 
-	wasChanged, err := botDb.UpdateRlUsernameByTwitchLogin(user, newUsername)
+	wasChanged, err := botDb.UpdateRlUsernameByTwitchUserId(twitchUserId, newUsername)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" There was an error updating the username")
+		reply(message, "update_error")
 		log.WithField("event", "setusername_command_db_update").Error(err)
 		return
 	}
 	if !wasChanged {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot is not joined")
+		reply(message, "not_joined")
 		return
 	}
-	redisCache.Delete("twitch:" + user)
-	client.Say(message.Channel, "@"+message.User.Name+" Username updated")
+	invalidateChannelCache(twitchUserId)
+	reply(message, "username_set")
 }
 
-func setFormatCommand(message *twitch.PrivateMessage, client *twitch.Client) {
+func setFormatCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
 	log.WithField("event", "setformat_command").WithField("channel", message.Channel).Info("Executing setformat command")
-	cmdContent := strings.SplitN(message.Message, "!setformat ", 2)
-	if len(cmdContent) != 2 {
-		client.Say(message.Channel, "@"+message.User.Name+" Syntax: \"!setformat format\"")
+	newFormat := args[0]
+
+	_, err := rankformat.Parse(newFormat)
+	if err != nil {
+		reply(message, "invalid_format", err.Error())
 		return
 	}
-	newFormat := cmdContent[1]
 
-	var user string
-	if message.Channel == configuration.TwitchUsername {
-		user = message.User.Name
-	} else {
-		user = message.Channel
-	}
+	twitchUserId := channelOwnerId(message)
 
-	wasChanged, err := botDb.UpdateRlMsgFormatByTwitchLogin(user, newFormat)
+	wasChanged, err := botDb.UpdateRlMsgFormatByTwitchUserId(twitchUserId, newFormat)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" There was an error updating the format")
+		reply(message, "update_error")
 		log.WithField("event", "setformat_command_db_update").Error(err)
 		return
 	}
 	if !wasChanged {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot is not joined")
+		reply(message, "not_joined")
 		return
 	}
-	redisCache.Delete("twitch:" + user)
-	client.Say(message.Channel, "@"+message.User.Name+" Format updated")
+	invalidateChannelCache(twitchUserId)
+	reply(message, "format_set")
 }
 
-func setCmdCommand(message *twitch.PrivateMessage, client *twitch.Client) {
-	log.WithField("event", "setcmd_command").WithField("channel", message.Channel).Info("Executing setcmd command")
-	cmdContent := strings.SplitN(message.Message, "!setcmd ", 2)
-	if len(cmdContent) != 2 {
-		client.Say(message.Channel, "@"+message.User.Name+" Syntax: \"!setcmd command\"")
+func previewFormatCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "previewformat_command").WithField("channel", message.Channel).Info("Executing previewformat command")
+
+	tmpl, err := rankformat.Parse(args[0])
+	if err != nil {
+		reply(message, "invalid_format", err.Error())
+		return
+	}
+
+	twitchUserId := channelOwnerId(message)
+
+	dbUser, err := botDb.GetBotUserByTwitchUserId(twitchUserId)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	if dbUser.RlPlatform == "" || dbUser.RlUsername == "" {
+		reply(message, "setup_first")
+		return
+	}
+
+	replyStr, err := GetRankString(dbUser.TwitchUserId, dbUser.RlPlatform, dbUser.RlUsername, tmpl, message.User.Name, languageOf(message))
+	if err != nil {
+		if _, ok := err.(*PlayerNotFoundError); ok {
+			reply(message, "player_not_found", dbUser.RlUsername, dbUser.RlPlatform)
+			return
+		}
+		reply(message, "preview_error")
+		log.WithField("event", "previewformat_command_get_rank_str").Error(err)
 		return
 	}
-	newCmd := cmdContent[1]
+
+	reply(message, "preview", chatMessage(replyStr))
+}
+
+func setCmdCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "setcmd_command").WithField("channel", message.Channel).Info("Executing setcmd command")
+	newCmd := args[0]
 	if strings.HasPrefix(newCmd, "!") {
 		newCmd = strings.TrimPrefix(newCmd, "!")
 	}
 
 	if len(newCmd) > 50 {
-		client.Say(message.Channel, "@"+message.User.Name+" This command is too long.")
+		reply(message, "command_too_long")
 		return
 	}
 
-	var user string
-	if message.Channel == configuration.TwitchUsername {
-		user = message.User.Name
-	} else {
-		user = message.Channel
+	twitchUserId, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	commands, err := botDb.GetChannelCommands(dbUser.TwitchUserId)
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "setcmd_command_db_get").Error(err)
+		return
+	}
+	triggers := usedTriggers(dbUser, commands, "")
+	delete(triggers, strings.ToLower(dbUser.TwitchCommandName))
+	if triggers[strings.ToLower(newCmd)] {
+		reply(message, "command_exists", "!"+newCmd)
+		return
 	}
 
-	wasChanged, err := botDb.UpdateTwitchCommandNameByTwitchLogin(user, newCmd)
+	wasChanged, err := botDb.UpdateTwitchCommandNameByTwitchUserId(twitchUserId, newCmd)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" There was an error updating the command")
+		reply(message, "update_error")
 		log.WithField("event", "setcmd_command_db_update").Error(err)
 		return
 	}
 	if !wasChanged {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot is not joined")
+		reply(message, "not_joined")
 		return
 	}
-	redisCache.Delete("twitch:" + user)
-	client.Say(message.Channel, "@"+message.User.Name+" Command updated to !"+newCmd)
+	invalidateChannelCache(twitchUserId)
+	reply(message, "command_set", "!"+newCmd)
 }
 
-func setCooldownCommand(message *twitch.PrivateMessage, client *twitch.Client) {
+func setCooldownCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
 	log.WithField("event", "setcooldown_command").WithField("channel", message.Channel).Info("Executing setcooldown command")
-	cmdContent := strings.SplitN(message.Message, "!setcooldown ", 2)
-	if len(cmdContent) != 2 {
-		client.Say(message.Channel, "@"+message.User.Name+" Syntax: \"!setcooldown seconds\"")
+	newCooldown, err := strconv.ParseInt(args[0], 10, 0)
+	if err != nil || newCooldown < 10 {
+		reply(message, "cooldown_invalid", "10")
 		return
 	}
-	newCooldown, err := strconv.ParseInt(cmdContent[1], 10, 0)
-	if err != nil || newCooldown < 10 {
-		client.Say(message.Channel, "@"+message.User.Name+" The cooldown has to be a positive integer of at least 10.")
+
+	twitchUserId := channelOwnerId(message)
+
+	wasChanged, err := botDb.UpdateTwitchCommandCooldownByTwitchUserId(twitchUserId, int(newCooldown))
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "setcooldown_command_db_update").Error(err)
+		return
+	}
+	if !wasChanged {
+		reply(message, "not_joined")
 		return
 	}
+	invalidateChannelCache(twitchUserId)
+	reply(message, "cooldown_set", strconv.FormatInt(newCooldown, 10))
+}
+
+func setLookupCooldownCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "setlookupcooldown_command").WithField("channel", message.Channel).Info("Executing setlookupcooldown command")
+	newCooldown, err := strconv.ParseInt(args[0], 10, 0)
+	if err != nil || newCooldown < 30 {
+		reply(message, "cooldown_invalid", "30")
+		return
+	}
+
+	twitchUserId := channelOwnerId(message)
+
+	wasChanged, err := botDb.UpdateLookupCooldownByTwitchUserId(twitchUserId, int(newCooldown))
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "setlookupcooldown_command_db_update").Error(err)
+		return
+	}
+	if !wasChanged {
+		reply(message, "not_joined")
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	reply(message, "lookup_cooldown_set", strconv.FormatInt(newCooldown, 10))
+}
+
+func setUserCooldownCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "setusercooldown_command").WithField("channel", message.Channel).Info("Executing setusercooldown command")
+	newCooldown, err := strconv.ParseInt(args[0], 10, 0)
+	if err != nil || newCooldown < 0 || newCooldown > 86400 {
+		reply(message, "user_cooldown_invalid")
+		return
+	}
+
+	twitchUserId := channelOwnerId(message)
 
-	var user string
-	if message.Channel == configuration.TwitchUsername {
-		user = message.User.Name
+	wasChanged, err := botDb.UpdateUserCooldownByTwitchUserId(twitchUserId, int(newCooldown))
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "setusercooldown_command_db_update").Error(err)
+		return
+	}
+	if !wasChanged {
+		reply(message, "not_joined")
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	if newCooldown == 0 {
+		reply(message, "user_cooldown_off")
 	} else {
-		user = message.Channel
+		reply(message, "user_cooldown_set", strconv.FormatInt(newCooldown, 10))
 	}
+}
+
+func setPrefixCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "setprefix_command").WithField("channel", message.Channel).Info("Executing setprefix command")
+	newPrefix := args[0]
+	if !isValidPrefix(newPrefix) {
+		reply(message, "prefix_invalid")
+		return
+	}
+
+	twitchUserId := channelOwnerId(message)
 
-	wasChanged, err := botDb.UpdateTwitchCommandCooldownByTwitchLogin(user, int(newCooldown))
+	wasChanged, err := botDb.UpdateCommandPrefixByTwitchUserId(twitchUserId, newPrefix)
 	if err != nil {
-		client.Say(message.Channel, "@"+message.User.Name+" There was an error updating the cooldown")
-		log.WithField("event", "setcooldown_command_db_update").Error(err)
+		reply(message, "update_error")
+		log.WithField("event", "setprefix_command_db_update").Error(err)
 		return
 	}
 	if !wasChanged {
-		client.Say(message.Channel, "@"+message.User.Name+" The bot is not joined")
+		reply(message, "not_joined")
 		return
 	}
-	redisCache.Delete("twitch:" + user)
-	client.Say(message.Channel, "@"+message.User.Name+" Cooldown updated to "+strconv.FormatInt(newCooldown, 10)+" seconds")
+	invalidateChannelCache(twitchUserId)
+	reply(message, "prefix_set", newPrefix)
 }
 
-type CachedChannel struct {
-	Command         string `json:"cmd"`
-	LastExecuted    int64  `json:"last"`
-	CooldownSeconds int64  `json:"cd"`
-	RlPlatform      string `json:"rlp"`
-	RlUsername      string `json:"rlu"`
-	MessageFormat   string `json:"fmt"`
+func setLangCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "setlang_command").WithField("channel", message.Channel).Info("Executing setlang command")
+	newLanguage := strings.ToLower(args[0])
+	if !isSupportedLanguage(newLanguage) {
+		reply(message, "language_invalid", strings.Join(supportedLanguages(), ", "))
+		return
+	}
+
+	twitchUserId := channelOwnerId(message)
+
+	wasChanged, err := botDb.UpdateLanguageByTwitchUserId(twitchUserId, newLanguage)
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", "setlang_command_db_update").Error(err)
+		return
+	}
+	if !wasChanged {
+		reply(message, "not_joined")
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	reply(message, "language_set")
 }
 
-func checkForRankCommand(message *twitch.PrivateMessage, client *twitch.Client) {
-	cachedStr, err := redisCache.Get("twitch:" + message.Channel)
-	if err == nil {
-		cachedObj := CachedChannel{}
-		err = json.Unmarshal([]byte(cachedStr), &cachedObj)
-		if err != nil {
-			return
-		}
-		if cachedObj.LastExecuted > time.Now().Unix()-cachedObj.CooldownSeconds || !strings.HasPrefix(message.Message, "!"+cachedObj.Command) {
+func resetSessionCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "resetsession_command").WithField("channel", message.Channel).Info("Executing resetsession command")
+
+	twitchUserId := channelOwnerId(message)
+
+	dbUser, err := botDb.GetBotUserByTwitchUserId(twitchUserId)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	if dbUser.RlPlatform == "" || dbUser.RlUsername == "" {
+		reply(message, "setup_first")
+		return
+	}
+
+	err = recordSessionBaseline(dbUser.TwitchUserId, dbUser.RlPlatform, dbUser.RlUsername)
+	if err != nil {
+		if _, ok := err.(*PlayerNotFoundError); ok {
+			reply(message, "player_not_found", dbUser.RlUsername, dbUser.RlPlatform)
 			return
 		}
-		log.WithField("event", "rank_command").WithField("channel", message.Channel).Info("Executing rank command")
-		metricRankCommandsExecuted.Inc()
-		metricRankCommandsCacheHits.Inc()
+		reply(message, "session_reset_error")
+		log.WithField("event", "resetsession_command").Error(err)
+		return
+	}
+	reply(message, "session_reset")
+}
 
-		replyStr, err := GetRankString(cachedObj.RlPlatform, cachedObj.RlUsername, cachedObj.MessageFormat)
-		if err != nil {
-			if _, ok := err.(*PlayerNotFoundError); ok {
-				client.Say(message.Channel, "Player "+cachedObj.RlUsername+" was not found on platform "+cachedObj.RlPlatform)
-				return
-			}
-			client.Say(message.Channel, "There was an error getting the rank for player "+cachedObj.RlUsername+" on platform "+cachedObj.RlPlatform)
-			log.WithField("event", "user_command_get_rank_str").Error(err)
-		} else {
-			replyStr = strings.Replace(replyStr, "$(user)", message.User.Name, -1)
-			if strings.HasPrefix(replyStr, "$(reply)") {
-				replyStr = strings.TrimPrefix(replyStr, "$(reply)")
-				replyStr = strings.TrimLeft(replyStr, "/ ")
-				client.Reply(message.Channel, message.ID, substr(replyStr, 0, 500))
-			} else {
-				replyStr = strings.TrimLeft(replyStr, "/ ")
-				client.Say(message.Channel, substr(replyStr, 0, 500))
-			}
-		}
+func checkForRankCommand(message *twitch.PrivateMessage, client *twitch.Client, channel *CachedChannel, fromCache bool, input string, mentioned bool) {
+	if channel.MentionOnly && !mentioned {
+		return
+	}
+	msgParts := strings.Fields(input)
+	if len(msgParts) == 0 {
+		return
+	}
+	trigger, ok := commandWord(msgParts[0], channel.Prefix, mentioned)
+	if !ok {
+		return
+	}
+	cmdName, ok := channel.Triggers[trigger]
+	if !ok {
+		return
+	}
 
-		cachedObj.LastExecuted = time.Now().Unix()
-		toCacheStr, _ := json.Marshal(cachedObj)
-		err = redisCache.SetWithTtl("twitch:"+message.Channel, string(toCacheStr), time.Hour)
-		if err != nil {
-			log.WithField("event", "user_command_cache_set").Error(err)
-		}
+	if channel.LiveOnly && !isChannelLive(channel.TwitchUserId) {
 		return
 	}
 
-	dbUser, err := botDb.GetBotUserByTwitchLogin(message.Channel)
+	cmd, err := getCachedCommand(message.RoomID, cmdName)
 	if err != nil {
-		log.WithField("event", "user_command_get_db").Warn(err)
+		log.WithField("event", "user_command_get_cmd").Warn(err)
 		return
 	}
 
-	if message.Message == "!"+dbUser.TwitchCommandName {
+	if channel.LookupEnabled && len(msgParts) >= 3 {
+		lookupPlayer(message, client, channel, cmd, msgParts[1], strings.Join(msgParts[2:], " "))
+		return
+	}
+	if !startCommandCooldown(message, channel, cmd) {
+		return
+	}
 
-		platformMissing := dbUser.RlPlatform == ""
-		usernameMissing := dbUser.RlUsername == ""
+	platformMissing := cmd.RlPlatform == ""
+	usernameMissing := cmd.RlUsername == ""
 
-		if platformMissing && usernameMissing {
-			client.Say(message.Channel, "Please complete the setup with \"@"+configuration.TwitchUsername+" !set platform username\"")
-			return
-		} else if platformMissing {
-			client.Say(message.Channel, "Please set your platform with \"@"+configuration.TwitchUsername+" !setplatform platform\"")
-			return
-		} else if usernameMissing {
-			client.Say(message.Channel, "Please set your username with \"@"+configuration.TwitchUsername+" !setusername username\"")
-			return
-		}
+	if platformMissing && usernameMissing {
+		announce(message, "setup_incomplete", configuration.TwitchUsername)
+		return
+	} else if platformMissing {
+		announce(message, "setup_platform", configuration.TwitchUsername)
+		return
+	} else if usernameMissing {
+		announce(message, "setup_username", configuration.TwitchUsername)
+		return
+	}
 
-		log.WithField("event", "rank_command").WithField("channel", message.Channel).Info("Executing rank command")
-		metricRankCommandsExecuted.Inc()
+	log.WithField("event", "rank_command").WithField("channel", message.Channel).WithField("command", cmd.Name).Info("Executing rank command")
+	metricRankCommandsExecuted.Inc()
+	if fromCache {
+		metricRankCommandsCacheHits.Inc()
+	}
 
-		toCache := CachedChannel{
-			Command:         dbUser.TwitchCommandName,
-			LastExecuted:    time.Now().Unix(),
-			CooldownSeconds: int64(dbUser.TwitchCommandCooldown),
-			RlPlatform:      dbUser.RlPlatform,
-			RlUsername:      dbUser.RlUsername,
-			MessageFormat:   dbUser.RlMessageFormat,
-		}
-		toCacheStr, _ := json.Marshal(toCache)
-		err := redisCache.SetWithTtl("twitch:"+message.Channel, string(toCacheStr), time.Hour)
-		if err != nil {
-			log.WithField("event", "user_command_cache_set").Error(err)
-		}
+	sendRankReply(message, client, channel.TwitchUserId, cmd.RlPlatform, cmd.RlUsername, cmd.MessageFormat)
+}
 
-		replyStr, err := GetRankString(dbUser.RlPlatform, dbUser.RlUsername, dbUser.RlMessageFormat)
-		if err != nil {
-			if _, ok := err.(*PlayerNotFoundError); ok {
-				client.Say(message.Channel, "Player "+dbUser.RlUsername+" was not found on platform "+dbUser.RlPlatform)
-				return
-			}
-			client.Say(message.Channel, "There was an error getting the rank for player "+dbUser.RlUsername+" on platform "+dbUser.RlPlatform)
-			log.WithField("event", "user_command_get_rank_str").Error(err)
+var platformAliases = map[string]string{"psn": trackernet.PS, "playstation": trackernet.PS, "xbl": trackernet.Xbox}
+
+// lookupPlayer answers "!rank platform username" for arbitrary players, rate limited by the channel's lookup cooldown.
+func lookupPlayer(message *twitch.PrivateMessage, client *twitch.Client, channel *CachedChannel, cmd *CachedCommand, platform string, username string) {
+	platform = strings.ToLower(platform)
+	if alias, ok := platformAliases[platform]; ok {
+		platform = alias
+	}
+	if !validPlatforms[platform] || len(username) > 255 {
+		return
+	}
+
+	allowed, err := redisCache.SetIfAbsent(lookupCooldownKey(message.RoomID), message.User.Name, time.Second*time.Duration(channel.LookupCooldown))
+	if err != nil {
+		log.WithField("event", "lookup_cooldown").Error(err)
+		return
+	}
+	if !allowed {
+		return
+	}
+
+	log.WithField("event", "lookup_command").WithField("channel", message.Channel).WithField("command", cmd.Name).Info("Executing player lookup")
+	metricRankCommandsExecuted.Inc()
+	// session deltas belong to the channel's own player and are not available for lookups
+	sendRankReply(message, client, "", platform, username, cmd.MessageFormat)
+}
+
+// sendRankReply renders the channel's format for the given player.
+func sendRankReply(message *twitch.PrivateMessage, client *twitch.Client, twitchUserId string, platform string, username string, format string) {
+	tmpl := rankformat.ParseStored(format)
+
+	replyStr, err := GetRankString(twitchUserId, platform, username, tmpl, message.User.Name, languageOf(message))
+	if err != nil {
+		if _, ok := err.(*PlayerNotFoundError); ok {
+			announce(message, "player_not_found", username, platform)
 			return
 		}
+		announce(message, "rank_error", username, platform)
+		log.WithField("event", "user_command_get_rank_str").Error(err)
+		return
+	}
 
-		replyStr = strings.Replace(replyStr, "$(user)", message.User.Name, -1)
-		if strings.HasPrefix(replyStr, "$(reply)") {
-			replyStr = strings.TrimPrefix(replyStr, "$(reply)")
-			replyStr = strings.TrimLeft(replyStr, "/ ")
-			client.Reply(message.Channel, message.ID, substr(replyStr, 0, 500))
-		} else {
-			replyStr = strings.TrimLeft(replyStr, "/ ")
-			client.Say(message.Channel, substr(replyStr, 0, 500))
-		}
+	if tmpl.Reply {
+		outbound.Reply(message.Channel, message.ID, replyStr)
+	} else {
+		outbound.Say(message.Channel, replyStr)
 	}
 }
 
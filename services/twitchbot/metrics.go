@@ -22,4 +22,32 @@ var (
 		Name: "twitchbot_rank_commands_cache_hits",
 		Help: "Total number of rank command cache hits",
 	})
+	metricCommandsOnCooldown = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "twitchbot_commands_on_cooldown",
+		Help: "Total number of rank commands ignored because of a cooldown",
+	})
+	metricWhispersDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "twitchbot_whispers_dropped",
+		Help: "Total number of whispers dropped because of the whisper rate limit",
+	})
+	metricOutboundQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "twitchbot_outbound_queue_depth",
+		Help: "Number of chat messages waiting to be sent",
+	})
+	metricOutboundSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "twitchbot_outbound_messages_sent",
+		Help: "Total number of chat messages sent",
+	})
+	metricOutboundDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "twitchbot_outbound_messages_dropped",
+		Help: "Chat messages dropped because they were duplicates, expired or the queue was full",
+	}, []string{"reason"})
+	metricEventSubNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "twitchbot_eventsub_notifications",
+		Help: "Total number of received EventSub notifications",
+	}, []string{"type"})
+	metricRankAnnouncements = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "twitchbot_rank_announcements",
+		Help: "Total number of announced rank changes",
+	})
 )
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/cache"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Twitch limits how many messages an account may send, the global limit is raised
+// for messages in channels where the account is moderator or broadcaster.
+// Non moderators may additionally only send one message per second in a channel
+// and identical messages are rejected within 30 seconds.
+// The limits apply to the account, so they are counted in redis and shared by all replicas of the bot.
+const (
+	defaultGlobalLimit     = 20
+	defaultModGlobalLimit  = 100
+	globalLimitWindow      = time.Second * 30
+	defaultChannelInterval = 1100
+	defaultMaxChannelQueue = 10
+	defaultMaxMessageAge   = 30
+	duplicateMessageWindow = time.Second * 30
+	maxChatMessageLength   = 500
+	outboundDispatchPeriod = time.Millisecond * 50
+)
+
+type outboundMessage struct {
+	channel  string
+	parentId string
+	text     string
+	enqueued time.Time
+}
+
+type sentMessage struct {
+	text string
+	at   time.Time
+}
+
+// Outbound queues all chat messages of the bot and sends them within the Twitch rate limits.
+type Outbound struct {
+	client          *twitch.Client
+	mu              sync.Mutex
+	queues          map[string][]*outboundMessage
+	order           []string
+	globalWindow    cache.RateWindow
+	modGlobalWindow cache.RateWindow
+	moderator       map[string]bool
+	lastSent        map[string]sentMessage
+	maxQueue        int
+	maxAge          time.Duration
+	channelInterval time.Duration
+}
+
+func NewOutbound(client *twitch.Client, config OutboundConfiguration) *Outbound {
+	if config.GlobalLimit <= 0 {
+		config.GlobalLimit = defaultGlobalLimit
+	}
+	if config.ModGlobalLimit <= 0 {
+		config.ModGlobalLimit = defaultModGlobalLimit
+	}
+	if config.ChannelIntervalMs <= 0 {
+		config.ChannelIntervalMs = defaultChannelInterval
+	}
+	if config.MaxChannelQueue <= 0 {
+		config.MaxChannelQueue = defaultMaxChannelQueue
+	}
+	if config.MaxMessageAge <= 0 {
+		config.MaxMessageAge = defaultMaxMessageAge
+	}
+
+	return &Outbound{
+		client:          client,
+		queues:          make(map[string][]*outboundMessage),
+		order:           make([]string, 0),
+		globalWindow:    cache.RateWindow{Key: "twitch:chat:global", Limit: config.GlobalLimit, Window: globalLimitWindow},
+		modGlobalWindow: cache.RateWindow{Key: "twitch:chat:global_mod", Limit: config.ModGlobalLimit, Window: globalLimitWindow},
+		moderator:       make(map[string]bool),
+		lastSent:        make(map[string]sentMessage),
+		maxQueue:        config.MaxChannelQueue,
+		maxAge:          time.Second * time.Duration(config.MaxMessageAge),
+		channelInterval: time.Millisecond * time.Duration(config.ChannelIntervalMs),
+	}
+}
+
+// chatMessage strips a leading "/" so user controlled text can not run chat commands and cuts the text to the
+// length Twitch accepts.
+func chatMessage(text string) string {
+	return substr(strings.TrimLeft(text, "/ "), 0, maxChatMessageLength)
+}
+
+func (o *Outbound) Say(channel string, text string) {
+	o.enqueue(&outboundMessage{channel: channel, text: chatMessage(text), enqueued: time.Now()})
+}
+
+func (o *Outbound) Reply(channel string, parentId string, text string) {
+	o.enqueue(&outboundMessage{channel: channel, parentId: parentId, text: chatMessage(text), enqueued: time.Now()})
+}
+
+// SetModerator records whether the bot is moderator in a channel, as reported by USERSTATE.
+func (o *Outbound) SetModerator(channel string, isMod bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.moderator[channel] = isMod
+}
+
+func (o *Outbound) enqueue(msg *outboundMessage) {
+	if msg.text == "" {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	queue := o.queues[msg.channel]
+	for _, queued := range queue {
+		// replies to different messages are not duplicates, the last sent check still applies in non mod channels
+		if queued.text == msg.text && queued.parentId == msg.parentId {
+			metricOutboundDropped.WithLabelValues("duplicate").Inc()
+			return
+		}
+	}
+	if len(queue) >= o.maxQueue {
+		metricOutboundDropped.WithLabelValues("queue_full").Inc()
+		return
+	}
+	if len(queue) == 0 {
+		o.order = append(o.order, msg.channel)
+	}
+	o.queues[msg.channel] = append(queue, msg)
+	metricOutboundQueueDepth.Inc()
+}
+
+// Run sends queued messages until the process exits. Channels take turns so a busy channel can not starve the others.
+func (o *Outbound) Run() {
+	ticker := time.NewTicker(outboundDispatchPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		o.dispatch(time.Now())
+	}
+}
+
+func (o *Outbound) dispatch(now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	remaining := make([]string, 0, len(o.order))
+	for _, channel := range o.order {
+		if o.sendNext(channel, now) {
+			remaining = append(remaining, channel)
+		}
+	}
+	o.order = remaining
+}
+
+// sendNext sends or drops the oldest message of the channel if the limits allow it and reports whether messages are left.
+func (o *Outbound) sendNext(channel string, now time.Time) bool {
+	queue := o.queues[channel]
+	for len(queue) > 0 && now.Sub(queue[0].enqueued) > o.maxAge {
+		metricOutboundDropped.WithLabelValues("expired").Inc()
+		metricOutboundQueueDepth.Dec()
+		queue = queue[1:]
+	}
+	if len(queue) == 0 {
+		delete(o.queues, channel)
+		return false
+	}
+	o.queues[channel] = queue
+
+	msg := queue[0]
+	isMod := o.moderator[channel]
+	if !isMod {
+		if last, ok := o.lastSent[channel]; ok && last.text == msg.text && now.Sub(last.at) < duplicateMessageWindow {
+			metricOutboundDropped.WithLabelValues("duplicate").Inc()
+			metricOutboundQueueDepth.Dec()
+			o.queues[channel] = queue[1:]
+			return len(queue) > 1
+		}
+	}
+
+	windows := []cache.RateWindow{o.modGlobalWindow}
+	if !isMod {
+		windows = append(windows, o.globalWindow, cache.RateWindow{Key: "twitch:chat:channel:" + channel, Limit: 1, Window: o.channelInterval})
+	}
+	allowed, err := redisCache.TakeRateLimit(windows...)
+	if err != nil {
+		log.WithField("event", "outbound_rate_limit").Error(err)
+		return true
+	}
+	if !allowed {
+		return true
+	}
+
+	if msg.parentId != "" {
+		o.client.Reply(channel, msg.parentId, msg.text)
+	} else {
+		o.client.Say(channel, msg.text)
+	}
+	o.lastSent[channel] = sentMessage{text: msg.text, at: now}
+	metricOutboundSent.Inc()
+	metricOutboundQueueDepth.Dec()
+
+	o.queues[channel] = queue[1:]
+	return len(queue) > 1
+}
+
+// Forget drops all state of a channel the bot left.
+func (o *Outbound) Forget(channel string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	metricOutboundQueueDepth.Sub(float64(len(o.queues[channel])))
+	delete(o.queues, channel)
+	for i, queued := range o.order {
+		if queued == channel {
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			break
+		}
+	}
+	delete(o.moderator, channel)
+	delete(o.lastSent, channel)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChatMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "Ranked 2v2: GC1", "Ranked 2v2: GC1"},
+		{"command", "/ban chatter", "ban chatter"},
+		{"command after spaces", "  //me hi", "me hi"},
+		{"slash inside", "1v1 / 2v2", "1v1 / 2v2"},
+		{"only a command", "/ ", ""},
+		{"too long", strings.Repeat("é", maxChatMessageLength+10), strings.Repeat("é", maxChatMessageLength)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := chatMessage(test.text); got != test.want {
+				t.Errorf("chatMessage(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestOutboundQueuesSanitizedMessages(t *testing.T) {
+	o := NewOutbound(nil, OutboundConfiguration{})
+	o.Say("channel", "/timeout chatter 600")
+	o.Reply("channel", "parent", "/"+strings.Repeat("a", maxChatMessageLength+1))
+	o.Say("channel", "/")
+
+	queue := o.queues["channel"]
+	if len(queue) != 2 {
+		t.Fatalf("expected 2 queued messages, got %d", len(queue))
+	}
+	if queue[0].text != "timeout chatter 600" {
+		t.Errorf("queued %q", queue[0].text)
+	}
+	if queue[1].text != strings.Repeat("a", maxChatMessageLength) || queue[1].parentId != "parent" {
+		t.Errorf("queued reply %q to %q", queue[1].text, queue[1].parentId)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+type Role int
+
+const (
+	RoleEveryone Role = iota
+	RoleSubscriber
+	RoleVip
+	RoleModerator
+	RoleBroadcaster
+)
+
+// CommandPermission is the minimum role required to run a command.
+// Delegable commands can also be run by users the broadcaster delegated them to.
+type CommandPermission struct {
+	MinRole        Role
+	Delegable      bool
+	BotChannelOnly bool
+}
+
+func isBotChannel(message *twitch.PrivateMessage) bool {
+	return message.Channel == strings.ToLower(configuration.TwitchUsername)
+}
+
+// channelOwnerId returns the user id of the channel a command applies to.
+// In the bot's own channel that is the chatter's channel.
+func channelOwnerId(message *twitch.PrivateMessage) string {
+	if isBotChannel(message) {
+		return message.User.ID
+	}
+	return message.RoomID
+}
+
+// roleOf returns the highest role of the chatter in the channel the message was sent in.
+// In the bot's own channel everyone manages their own channel and is treated as broadcaster.
+func roleOf(message *twitch.PrivateMessage) Role {
+	badges := message.User.Badges
+	switch {
+	case isBotChannel(message) || message.User.ID == message.RoomID || badges["broadcaster"] > 0:
+		return RoleBroadcaster
+	case badges["moderator"] > 0 || message.Tags["mod"] == "1":
+		return RoleModerator
+	case badges["vip"] > 0:
+		return RoleVip
+	case badges["subscriber"] > 0 || badges["founder"] > 0:
+		return RoleSubscriber
+	}
+	return RoleEveryone
+}
+
+// permissionChecker returns a check for the chatter of the message that looks up delegates at most once.
+func permissionChecker(message *twitch.PrivateMessage) func(perm CommandPermission) bool {
+	role := roleOf(message)
+	var isDelegate *bool
+	return func(perm CommandPermission) bool {
+		if perm.BotChannelOnly && !isBotChannel(message) {
+			return false
+		}
+		if role >= perm.MinRole {
+			return true
+		}
+		if !perm.Delegable || isBotChannel(message) {
+			return false
+		}
+
+		if isDelegate == nil {
+			delegate, err := botDb.IsChannelDelegate(message.RoomID, message.User.ID)
+			if err != nil {
+				log.WithField("event", "permission_check_delegate").Error(err)
+			}
+			isDelegate = &delegate
+		}
+		return *isDelegate
+	}
+}
+
+// hasPermission checks whether the chatter may run a command with the given permission in the channel of the message.
+func hasPermission(message *twitch.PrivateMessage, perm CommandPermission) bool {
+	return permissionChecker(message)(perm)
+}
+
+// lookupTwitchUser returns the account currently using the login, or nil if there is none.
+func lookupTwitchUser(login string) (*HelixUser, error) {
+	users, err := helix.GetUsers(nil, []string{login})
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return &users[0], nil
+}
+
+func delegateCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "delegate_command").WithField("channel", message.Channel).Info("Executing delegate command")
+	login := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(args[0]), "@"))
+	if login == "" || len(login) > 30 || strings.Contains(login, " ") {
+		reply(message, "syntax", "!delegate username")
+		return
+	}
+
+	_, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+
+	if helix == nil {
+		reply(message, "no_user_lookup")
+		return
+	}
+
+	// delegates are stored by user id, so a rename of the delegate does not hand the permissions to someone else
+	user, err := lookupTwitchUser(login)
+	if err != nil {
+		reply(message, "delegate_error")
+		log.WithField("event", "delegate_command_lookup").Error(err)
+		return
+	}
+	if user == nil {
+		reply(message, "twitch_user_not_found", login)
+		return
+	}
+
+	wasAdded, err := botDb.InsertChannelDelegate(dbUser.TwitchUserId, user.Id, user.Login)
+	if err != nil {
+		reply(message, "delegate_error")
+		log.WithField("event", "delegate_command_db_insert").Error(err)
+		return
+	}
+	if !wasAdded {
+		reply(message, "delegate_exists", user.Login)
+		return
+	}
+	reply(message, "delegate_added", user.Login)
+}
+
+func undelegateCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "undelegate_command").WithField("channel", message.Channel).Info("Executing undelegate command")
+	login := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(args[0]), "@"))
+
+	_, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+
+	delegateUserId := ""
+	if helix != nil {
+		user, err := lookupTwitchUser(login)
+		if err != nil {
+			reply(message, "undelegate_error")
+			log.WithField("event", "undelegate_command_lookup").Error(err)
+			return
+		}
+		if user != nil {
+			delegateUserId = user.Id
+		}
+	}
+
+	wasDeleted, err := botDb.DeleteChannelDelegate(dbUser.TwitchUserId, delegateUserId, login)
+	if err != nil {
+		reply(message, "undelegate_error")
+		log.WithField("event", "undelegate_command_db_delete").Error(err)
+		return
+	}
+	if !wasDeleted {
+		reply(message, "delegate_not_found", login)
+		return
+	}
+	reply(message, "delegate_removed", login)
+}
+
+func delegatesCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "delegates_command").WithField("channel", message.Channel).Info("Executing delegates command")
+
+	_, dbUser, err := commandManagementTarget(message)
+	if err != nil {
+		reply(message, "not_joined")
+		return
+	}
+	delegates, err := botDb.GetChannelDelegates(dbUser.TwitchUserId)
+	if err != nil {
+		reply(message, "list_error")
+		log.WithField("event", "delegates_command_db_get").Error(err)
+		return
+	}
+	if len(delegates) == 0 {
+		reply(message, "no_delegates")
+		return
+	}
+	reply(message, "delegates", strings.Join(delegateLogins(delegates), ", "))
+}
+
+// delegateLogins returns the current logins of the delegates. The stored login is used for accounts Helix does not
+// know anymore or when the lookup fails.
+func delegateLogins(delegates []ChannelDelegate) []string {
+	current := make(map[string]string)
+	if helix != nil {
+		for start := 0; start < len(delegates); start += helixUsersPerPage {
+			end := start + helixUsersPerPage
+			if end > len(delegates) {
+				end = len(delegates)
+			}
+			ids := make([]string, 0, end-start)
+			for _, delegate := range delegates[start:end] {
+				ids = append(ids, delegate.UserId)
+			}
+			users, err := helix.GetUsers(ids, nil)
+			if err != nil {
+				log.WithField("event", "delegates_command_lookup").Warn(err)
+				break
+			}
+			for _, user := range users {
+				current[user.Id] = user.Login
+			}
+		}
+	}
+
+	logins := make([]string, 0, len(delegates))
+	for _, delegate := range delegates {
+		if login, ok := current[delegate.UserId]; ok {
+			logins = append(logins, login)
+		} else {
+			logins = append(logins, delegate.Login)
+		}
+	}
+	sort.Strings(logins)
+	return logins
+}
@@ -3,24 +3,27 @@ package main
 import (
 	"encoding/json"
 	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/rankformat"
 	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
 	"net/http"
 	"net/url"
-	"regexp"
-	"strconv"
-	"strings"
 	"time"
-	"unicode/utf8"
 )
 
-func GetRankString(platform string, user string, format string) (string, error) {
+func GetRankString(twitchUserId string, platform string, user string, tmpl *rankformat.Template, chatter string, language string) (string, error) {
 
 	res, err := requestRank(platform, user)
 	if err != nil {
 		return "", err
 	}
 
-	return formatRankResponse(res, format), nil
+	var baselines *rankformat.Baselines
+	if tmpl.UsesDeltas {
+		baselines = loadMmrBaselines(twitchUserId, platform, user)
+	}
+
+	return tmpl.Render(res, baselines, chatter, rankNamer(language)), nil
 }
 
 type PlayerNotFoundError struct{}
@@ -34,8 +37,13 @@ var httpClient = http.Client{
 }
 
 func requestRank(platform string, user string) (*trackernet.GetRankResponse, error) {
+	return requestRankWithPriority(platform, user, webscraper.PriorityInteractive)
+}
 
-	reqUrl := configuration.TrackerNetServiceUrl + "/rank?platform=" + platform + "&user=" + url.QueryEscape(user)
+// requestRankWithPriority requests the ranks of a player, background polling uses the default priority to not delay chat commands.
+func requestRankWithPriority(platform string, user string, priority webscraper.Priority) (*trackernet.GetRankResponse, error) {
+
+	reqUrl := configuration.TrackerNetServiceUrl + "/rank?platform=" + platform + "&user=" + url.QueryEscape(user) + "&priority=" + string(priority)
 
 	req, err := http.NewRequest("GET", reqUrl, nil)
 	if err != nil {
@@ -65,115 +73,6 @@ func requestRank(platform string, user string) (*trackernet.GetRankResponse, err
 	return &rankRes, nil
 }
 
-var tokenMatcher = regexp.MustCompile("\\$\\(((\\w|\\.)+)\\)")
-
-func formatRankResponse(response *trackernet.GetRankResponse, format string) string {
-
-	var result strings.Builder
-
-	matchesBytes := tokenMatcher.FindAllStringIndex(format, -1)
-
-	var matches [][]int
-	for _, x := range matchesBytes {
-		matches = append(matches, []int{utf8.RuneCountInString(format[:x[0]]), utf8.RuneCountInString(format[:x[1]])})
-	}
-
-	if len(matches) == 0 {
-		return format
-	}
-
-	formatChars := []rune(format)
-	nextMatch := matches[0]
-	matchIndex := 0
-
-	for i, ch := range formatChars {
-		if i == nextMatch[0] {
-			// insert token for current match
-			token := formatChars[nextMatch[0]+2 : nextMatch[1]-1]
-			result.WriteString(evalToken(response, string(token)))
-		} else if i+1 == nextMatch[1] {
-			// use next match
-			matchIndex++
-			if matchIndex >= len(matches) {
-				nextMatch = []int{-1, -1}
-			} else {
-				nextMatch = matches[matchIndex]
-			}
-		} else if i > nextMatch[0] && i < nextMatch[1] {
-			// skip token chars
-			continue
-		} else {
-			// non token char
-			result.WriteRune(ch)
-		}
-	}
-
-	return result.String()
-}
-
-var tokenExtractor = regexp.MustCompile("^([u123hrdst])\\.([rdm])\\.?([sml])?$")
-
-func evalToken(response *trackernet.GetRankResponse, token string) string {
-	if token == "name" {
-		return response.DisplayName
-	}
-
-	matches := tokenExtractor.FindAllStringSubmatch(token, -1)
-	if len(matches) == 0 {
-		return "$(" + string(token) + ")"
-	}
-
-	playlist := playlistFromAbbr(matches[0][1])
-	stat := matches[0][2]
-	modifier := matches[0][3]
-
-	if modifier == "" {
-		modifier = "l"
-	}
-
-	for _, ranking := range response.Rankings {
-		if playlist == ranking.Playlist {
-			if stat == "r" {
-				return rankToStr(ranking.Rank, modifier)
-			} else if stat == "d" {
-				if modifier == "l" || modifier == "m" {
-					return toRoman(ranking.Division + 1)
-				} else {
-					return strconv.Itoa(ranking.Division + 1)
-				}
-			} else if stat == "m" {
-				return strconv.Itoa(ranking.Mmr)
-			}
-		}
-	}
-
-	return "[no_data:" + token + "]"
-}
-
-func playlistFromAbbr(abbr string) trackernet.Playlist {
-	switch abbr {
-	case "u":
-		return trackernet.Unranked
-	case "1":
-		return trackernet.Ranked1v1
-	case "2":
-		return trackernet.Ranked2v2
-	case "3":
-		return trackernet.Ranked3v3
-	case "h":
-		return trackernet.Hoops
-	case "r":
-		return trackernet.Rumble
-	case "d":
-		return trackernet.Dropshot
-	case "s":
-		return trackernet.Snowday
-	case "t":
-		return trackernet.Tournaments
-	}
-	return trackernet.Unranked
-}
-
 var ranksS = map[int]string{
 	0: "UR", 1: "B1", 2: "B2", 3: "B3", 4: "S1", 5: "S2", 6: "S3", 7: "G1", 8: "G2", 9: "G3",
 	10: "P1", 11: "P2", 12: "P3", 13: "D1", 14: "D2", 15: "D3",
@@ -195,10 +94,53 @@ var ranksL = map[int]string{
 	21: "Grand Champion III", 22: "Supersonic Legend",
 }
 
-func rankToStr(rank int, modifier string) string {
+// rankTiers are the translated tier names from Bronze to Grand Champion, ranks are built from them with roman divisions.
+type rankTiers struct {
+	unranked string
+	tiers    [7]string
+	ssl      string
+}
+
+func (t rankTiers) ranks() map[int]string {
+	ranks := map[int]string{0: t.unranked, 22: t.ssl}
+	for i, tier := range t.tiers {
+		for div := 1; div <= 3; div++ {
+			ranks[i*3+div] = tier + " " + rankformat.ToRoman(div)
+		}
+	}
+	return ranks
+}
+
+// localizedRanks contains the medium and long rank names by language. English and the short names use the tables above.
+var localizedRanks = map[string]map[string]map[int]string{
+	"de": {
+		"m": rankTiers{"Ungewertet", [7]string{"Bronze", "Silber", "Gold", "Platin", "Dia", "Champ", "Grand Champ"}, "SSL"}.ranks(),
+		"l": rankTiers{"Ungewertet", [7]string{"Bronze", "Silber", "Gold", "Platin", "Diamant", "Champion", "Grand Champion"}, "Supersonic Legend"}.ranks(),
+	},
+	"es": {
+		"m": rankTiers{"Sin clasificar", [7]string{"Bronce", "Plata", "Oro", "Platino", "Diamante", "Campeón", "Gran Campeón"}, "SSL"}.ranks(),
+		"l": rankTiers{"Sin clasificar", [7]string{"Bronce", "Plata", "Oro", "Platino", "Diamante", "Campeón", "Gran Campeón"}, "Leyenda Supersónica"}.ranks(),
+	},
+	"fr": {
+		"m": rankTiers{"Non classé", [7]string{"Bronze", "Argent", "Or", "Platine", "Diamant", "Champion", "Grand Champion"}, "SSL"}.ranks(),
+		"l": rankTiers{"Non classé", [7]string{"Bronze", "Argent", "Or", "Platine", "Diamant", "Champion", "Grand Champion"}, "Légende Supersonique"}.ranks(),
+	},
+}
+
+// rankNamer returns the rank names of the language for rendering rank formats.
+func rankNamer(language string) rankformat.RankNamer {
+	return func(rank int, modifier string) string {
+		return rankToStr(rank, modifier, language)
+	}
+}
+
+func rankToStr(rank int, modifier string, language string) string {
 	if rank > 22 {
 		return "?"
 	}
+	if names, ok := localizedRanks[language][modifier]; ok {
+		return names[rank]
+	}
 	if modifier == "s" {
 		return ranksS[rank]
 	} else if modifier == "m" {
@@ -208,17 +150,3 @@ func rankToStr(rank int, modifier string) string {
 	}
 	return "??"
 }
-
-func toRoman(num int) string {
-	switch num {
-	case 1:
-		return "I"
-	case 2:
-		return "II"
-	case 3:
-		return "III"
-	case 4:
-		return "IV"
-	}
-	return "?"
-}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestRankNamer(t *testing.T) {
+	tests := []struct {
+		language string
+		rank     int
+		modifier string
+		want     string
+	}{
+		{"en", 19, "l", "Grand Champion I"},
+		{"en", 13, "m", "Dia I"},
+		{"en", 19, "s", "GC1"},
+		{"es", 19, "l", "Gran Campeón I"},
+		{"es", 13, "m", "Diamante I"},
+		{"es", 19, "s", "GC1"},
+		{"de", 22, "l", "Supersonic Legend"},
+		{"xx", 13, "m", "Dia I"},
+		{"en", 23, "l", "?"},
+	}
+	for _, test := range tests {
+		got := rankNamer(test.language)(test.rank, test.modifier)
+		if got != test.want {
+			t.Errorf("rankNamer(%q)(%d, %q) = %q, want %q", test.language, test.rank, test.modifier, got, test.want)
+		}
+	}
+}
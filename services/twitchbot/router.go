@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/cache"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Arg describes a single command argument. A Rest argument takes the remaining message including spaces.
+type Arg struct {
+	Name     string
+	Optional bool
+	Rest     bool
+}
+
+type CommandHandler func(message *twitch.PrivateMessage, client *twitch.Client, args []string)
+
+// Command is a management command of the bot. Names and aliases are lowercase and without prefix.
+// The help text is looked up in the catalog as "help." followed by the name.
+type Command struct {
+	Name       string
+	Aliases    []string
+	Args       []Arg
+	Permission CommandPermission
+	Handler    CommandHandler
+}
+
+func (c *Command) Usage() string {
+	usage := "!" + c.Name
+	for _, arg := range c.Args {
+		if arg.Optional {
+			usage += " [" + arg.Name + "]"
+		} else {
+			usage += " " + arg.Name
+		}
+	}
+	return usage
+}
+
+var commandList = make([]*Command, 0)
+var commandRegistry = make(map[string]*Command)
+
+func registerCommand(cmd *Command) {
+	for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+		if _, exists := commandRegistry[name]; exists {
+			log.WithField("event", "register_command").Fatal("Duplicate command " + name)
+		}
+		commandRegistry[name] = cmd
+	}
+	commandList = append(commandList, cmd)
+}
+
+// publishCommandNames stores the names of all management commands for the api, which validates command names of
+// the dashboard against them.
+func publishCommandNames() {
+	names := make([]string, 0, len(commandRegistry))
+	for name := range commandRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	encoded, _ := json.Marshal(names)
+	err := redisCache.SetWithTtl(cache.TwitchBuiltinCommandsKey, string(encoded), 0)
+	if err != nil {
+		log.WithField("event", "publish_command_names").Error(err)
+	}
+}
+
+type token struct {
+	text  string
+	start int
+}
+
+// tokenize splits the input at spaces. Double quotes group an argument containing spaces.
+func tokenize(input string) []token {
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(input) {
+		if input[i] == ' ' {
+			i++
+			continue
+		}
+		start := i
+		if input[i] == '"' {
+			end := strings.IndexByte(input[i+1:], '"')
+			if end >= 0 {
+				tokens = append(tokens, token{text: input[i+1 : i+1+end], start: start})
+				i += end + 2
+				continue
+			}
+		}
+		end := strings.IndexByte(input[i:], ' ')
+		if end < 0 {
+			end = len(input) - i
+		}
+		tokens = append(tokens, token{text: input[i : i+end], start: start})
+		i += end
+	}
+	return tokens
+}
+
+// bindArgs maps the tokens following the command name to the arguments of the command.
+func bindArgs(cmd *Command, input string, tokens []token) ([]string, bool) {
+	args := make([]string, 0, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		if i >= len(tokens) {
+			if arg.Optional {
+				break
+			}
+			return nil, false
+		}
+		if arg.Rest {
+			// the raw input keeps quotes, which are part of formats and custom messages
+			return append(args, strings.TrimSpace(input[tokens[i].start:])), true
+		}
+		args = append(args, tokens[i].text)
+	}
+	return args, len(tokens) <= len(cmd.Args)
+}
+
+func isValidPrefix(prefix string) bool {
+	if prefix == "" || len(prefix) > 3 || strings.HasPrefix(prefix, "/") || strings.HasPrefix(prefix, ".") {
+		return false
+	}
+	for _, r := range prefix {
+		if r == '@' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// stripMention removes a leading mention of the bot and reports whether the message was addressed to it.
+func stripMention(message *twitch.PrivateMessage) (string, bool) {
+	mention := "@" + strings.ToLower(configuration.TwitchUsername)
+	input := strings.TrimSpace(message.Message)
+	if len(input) >= len(mention) && strings.ToLower(input[:len(mention)]) == mention {
+		rest := input[len(mention):]
+		if rest == "" || rest[0] == ' ' || rest[0] == ',' {
+			return strings.TrimSpace(strings.TrimPrefix(rest, ",")), true
+		}
+	}
+	return input, false
+}
+
+// mayBeCommand cheaply filters out chat messages that can not start with a command prefix.
+func mayBeCommand(input string) bool {
+	for _, r := range input {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+	}
+	return false
+}
+
+// commandWord strips the prefix from the first word of a command and lowercases it.
+// When the bot was mentioned the prefix is optional and "!" is always accepted.
+func commandWord(word string, prefix string, mentioned bool) (string, bool) {
+	if prefix != "" && strings.HasPrefix(word, prefix) {
+		return strings.ToLower(strings.TrimPrefix(word, prefix)), true
+	}
+	if mentioned {
+		return strings.ToLower(strings.TrimPrefix(word, "!")), true
+	}
+	return "", false
+}
+
+// dispatchCommand parses the input and runs the matching management command if the chatter is allowed to.
+// It reports whether the input named a management command.
+func dispatchCommand(message *twitch.PrivateMessage, client *twitch.Client, input string, prefix string, mentioned bool) bool {
+	tokens := tokenize(input)
+	if len(tokens) == 0 {
+		return false
+	}
+	name, ok := commandWord(tokens[0].text, prefix, mentioned)
+	if !ok {
+		return false
+	}
+	cmd, ok := commandRegistry[name]
+	if !ok {
+		return false
+	}
+	if !hasPermission(message, cmd.Permission) {
+		return true
+	}
+
+	args, ok := bindArgs(cmd, input, tokens[1:])
+	if !ok {
+		reply(message, "syntax", cmd.Usage())
+		return true
+	}
+	cmd.Handler(message, client, args)
+	return true
+}
+
+func helpCommand(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", "help_command").WithField("channel", message.Channel).Info("Executing help command")
+	allowed := permissionChecker(message)
+
+	if len(args) == 1 {
+		cmd, ok := commandRegistry[strings.ToLower(strings.TrimPrefix(args[0], "!"))]
+		if !ok || !allowed(cmd.Permission) {
+			reply(message, "unknown_command", args[0])
+			return
+		}
+		reply(message, "help", cmd.Usage(), translate(languageOf(message), "help."+cmd.Name))
+		return
+	}
+
+	names := make([]string, 0, len(commandList))
+	for _, cmd := range commandList {
+		if allowed(cmd.Permission) {
+			names = append(names, "!"+cmd.Name)
+		}
+	}
+	reply(message, "help_list", strings.Join(names, ", "))
+}
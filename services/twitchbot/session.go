@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/rankformat"
+	"github.com/yannismate/yannismate-api/libs/rest/trackernet"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// recordSessionBaseline stores the current MMR of the channel's player as the start of a new stream session.
+func recordSessionBaseline(twitchUserId string, platform string, user string) error {
+	res, err := requestRank(platform, user)
+	if err != nil {
+		return err
+	}
+	return botDb.ReplaceSessionBaseline(twitchUserId, res.Rankings)
+}
+
+func loadMmrBaselines(twitchUserId string, platform string, user string) *rankformat.Baselines {
+	baselines := rankformat.Baselines{}
+
+	session, err := botDb.GetSessionBaseline(twitchUserId)
+	if err != nil {
+		log.WithField("event", "get_session_baseline").Error(err)
+	} else {
+		baselines.Session = session
+	}
+
+	history, err := requestHistory(platform, user, time.Now().Add(-time.Hour*24))
+	if err != nil {
+		log.WithField("event", "get_history").Warn(err)
+	} else {
+		// entries are ordered by time and start with the last snapshot before the window
+		baselines.Day = make(map[trackernet.Playlist]int)
+		for _, entry := range history.Entries {
+			if _, ok := baselines.Day[entry.Playlist]; !ok {
+				baselines.Day[entry.Playlist] = entry.Mmr
+			}
+		}
+	}
+
+	return &baselines
+}
+
+func requestHistory(platform string, user string, since time.Time) (*trackernet.GetHistoryResponse, error) {
+
+	reqUrl := configuration.TrackerNetServiceUrl + "/history?platform=" + platform + "&user=" + url.QueryEscape(user) +
+		"&since=" + strconv.FormatInt(since.Unix(), 10) + "&baseline=1"
+
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "yannismate-api/services/twitchbot")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, errors.New("trackernet history responded with status " + strconv.Itoa(res.StatusCode))
+	}
+
+	var historyRes trackernet.GetHistoryResponse
+	err = json.NewDecoder(res.Body).Decode(&historyRes)
+	if err != nil {
+		return nil, err
+	}
+	return &historyRes, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/gempir/go-twitch-irc/v3"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// BoolSetting is an on/off channel setting managed with "!<name> on|off".
+// The replies are catalog keys, {0} in the messages is replaced with the name of the bot.
+type BoolSetting struct {
+	Name string
+	// Update stores the setting for the channel of the given user id and reports whether the channel exists.
+	Update func(twitchUserId string, enabled bool) (bool, error)
+	// Available is checked before the setting is turned on, Unavailable is the reply if it is not.
+	Available   func() bool
+	Unavailable string
+	OnReply     string
+	OffReply    string
+	// OnChange is called after the setting was stored, it is optional.
+	OnChange func(twitchUserId string, enabled bool)
+}
+
+func registerBoolSetting(setting *BoolSetting) {
+	registerCommand(&Command{
+		Name:       setting.Name,
+		Args:       onOffArg,
+		Permission: channelManager,
+		Handler: func(message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+			setBoolSetting(setting, message, client, args)
+		},
+	})
+}
+
+func setBoolSetting(setting *BoolSetting, message *twitch.PrivateMessage, client *twitch.Client, args []string) {
+	log.WithField("event", setting.Name+"_command").WithField("channel", message.Channel).Info("Executing " + setting.Name + " command")
+	state := strings.ToLower(args[0])
+	if state != "on" && state != "off" {
+		reply(message, "syntax", "!"+setting.Name+" on|off")
+		return
+	}
+	enabled := state == "on"
+	if enabled && setting.Available != nil && !setting.Available() {
+		reply(message, setting.Unavailable)
+		return
+	}
+
+	twitchUserId := channelOwnerId(message)
+
+	wasChanged, err := setting.Update(twitchUserId, enabled)
+	if err != nil {
+		reply(message, "update_error")
+		log.WithField("event", setting.Name+"_command_db_update").Error(err)
+		return
+	}
+	if !wasChanged {
+		reply(message, "not_joined")
+		return
+	}
+	invalidateChannelCache(twitchUserId)
+	if setting.OnChange != nil {
+		setting.OnChange(twitchUserId, enabled)
+	}
+	if enabled {
+		reply(message, setting.OnReply, configuration.TwitchUsername)
+	} else {
+		reply(message, setting.OffReply, configuration.TwitchUsername)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/yannismate/yannismate-api/libs/cache"
+	"strings"
+	"time"
+)
+
+// Twitch accepts at most 3 whispers per second and 100 per minute from an account. The windows are counted in
+// redis so all replicas of the bot share them. Whispers are only notices, so they are dropped instead of queued
+// when the limit is reached.
+var whisperRateWindows = []cache.RateWindow{
+	{Key: "twitch:whispers:s", Limit: 3, Window: time.Second},
+	{Key: "twitch:whispers:m", Limit: 100, Window: time.Minute},
+}
+
+var botUserId string
+
+// resolveBotUserId looks up the user id of the bot account, which Helix needs to send whispers.
+func resolveBotUserId() {
+	users, err := helix.GetUsers(nil, []string{strings.ToLower(configuration.TwitchUsername)})
+	if err != nil {
+		log.WithField("event", "resolve_bot_user").Error(err)
+		return
+	}
+	if len(users) == 0 {
+		log.WithField("event", "resolve_bot_user").Error("Bot account " + configuration.TwitchUsername + " not found")
+		return
+	}
+	botUserId = users[0].Id
+}
+
+func canWhisper() bool {
+	return helix != nil && botUserId != ""
+}
+
+// sendWhisper whispers text to a chatter through Helix, Twitch no longer delivers whispers sent over IRC.
+func sendWhisper(toUserId string, text string) {
+	if !canWhisper() {
+		return
+	}
+
+	allowed, err := redisCache.TakeRateLimit(whisperRateWindows...)
+	if err != nil {
+		log.WithField("event", "whisper_rate_limit").Error(err)
+		return
+	}
+	if !allowed {
+		metricWhispersDropped.Inc()
+		return
+	}
+
+	err = helix.SendWhisper(botUserId, toUserId, text)
+	if err != nil {
+		log.WithField("event", "send_whisper").Warn(err)
+	}
+}
@@ -1,10 +1,37 @@
 package main
 
+import "github.com/yannismate/yannismate-api/libs/rest/webscraper"
+
 type Configuration struct {
-	Selenium SeleniumConfiguration
+	Selenium    SeleniumConfiguration
+	Pool        PoolConfiguration
+	Queue       QueueConfiguration
+	Http        HttpConfiguration
+	DefaultMode webscraper.Mode
 }
 
 type SeleniumConfiguration struct {
 	Url             string
 	PageLoadTimeout int
 }
+
+type PoolConfiguration struct {
+	Size              int
+	MaxUses           int
+	AcquireTimeout    int
+	KeepAliveInterval int
+}
+
+type HttpConfiguration struct {
+	Timeout     int
+	MaxBodySize int64
+	Headers     map[string]string
+	Cookies     map[string]string
+}
+
+type QueueConfiguration struct {
+	Concurrency       int
+	MaxDepth          int
+	Timeout           int
+	RetryAfterSeconds int
+}
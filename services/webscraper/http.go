@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var httpClient *http.Client
+
+func scrapeHttp(url string) (*webscraper.GetScrapeResponse, error) {
+	start := time.Now()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range configuration.Http.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range configuration.Http.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	fetchDuration := time.Since(start)
+	start = time.Now()
+
+	var body io.Reader = res.Body
+	if configuration.Http.MaxBodySize > 0 {
+		body = io.LimitReader(res.Body, configuration.Http.MaxBodySize)
+	}
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webscraper.GetScrapeResponse{
+		Content:     string(content),
+		Mode:        webscraper.ModeHttp,
+		StatusCode:  res.StatusCode,
+		FinalUrl:    res.Request.URL.String(),
+		ContentType: res.Header.Get("Content-Type"),
+		Extraction:  webscraper.ExtractionBody,
+		Timings: webscraper.ScrapeTimings{
+			Fetch:   fetchDuration.Milliseconds(),
+			Extract: time.Since(start).Milliseconds(),
+		},
+	}, nil
+}
+
+var challengeMarkers = []string{"cf-browser-verification", "challenge-platform", "<title>Just a moment...</title>", "cf_chl_opt"}
+
+// looksLikeChallenge reports whether a plain http response is a bot protection interstitial
+// that has to be solved by a real browser.
+func looksLikeChallenge(res *webscraper.GetScrapeResponse) bool {
+	if res.StatusCode == 403 || res.StatusCode == 429 || res.StatusCode == 503 {
+		return true
+	}
+	for _, marker := range challengeMarkers {
+		if strings.Contains(res.Content, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -9,10 +9,13 @@ import (
 	"github.com/yannismate/yannismate-api/libs/httplog"
 	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 var configuration = Configuration{}
+var sessionPool *SessionPool
+var jobQueue *JobQueue
 
 func main() {
 
@@ -22,6 +25,14 @@ func main() {
 		return
 	}
 
+	httpClient = &http.Client{
+		Timeout: time.Millisecond * time.Duration(configuration.Http.Timeout),
+	}
+
+	jobQueue = NewJobQueue(configuration.Queue)
+	sessionPool = NewSessionPool(configuration.Pool, configuration.Selenium)
+	go sessionPool.KeepAlive(time.Millisecond * time.Duration(configuration.Pool.KeepAliveInterval))
+
 	http.Handle("/metrics", promhttp.Handler())
 	http.Handle("/scrape", httplog.WithLogging(scrapeHandler()))
 	err = http.ListenAndServe(":8080", nil)
@@ -35,87 +46,151 @@ var selCaps = selenium.Capabilities{"browserName": "chrome"}
 func scrapeHandler() http.Handler {
 	fn := func(rw http.ResponseWriter, r *http.Request) {
 
-		url := r.URL.Query().Get("url")
-		if url == "" {
+		scrapeReq, err := parseScrapeRequest(r)
+		if err != nil {
 			rw.WriteHeader(400)
+			_, _ = rw.Write([]byte(err.Error()))
 			return
 		}
+		mode := scrapeReq.Mode
+
+		start := time.Now()
+		var scrapeRes *webscraper.GetScrapeResponse
+
+		switch mode {
+		case webscraper.ModeHttp:
+			scrapeRes, err = scrapeHttp(scrapeReq.Url)
+		case webscraper.ModeBrowser:
+			scrapeRes, err = scrapeBrowser(scrapeReq)
+		case webscraper.ModeAuto:
+			scrapeRes, err = scrapeHttp(scrapeReq.Url)
+			if err != nil || looksLikeChallenge(scrapeRes) {
+				metricBrowserFallbacks.Inc()
+				scrapeRes, err = scrapeBrowser(scrapeReq)
+			}
+		}
 
-		remote, err := selenium.NewRemote(selCaps, configuration.Selenium.Url)
 		if err != nil {
-			log.WithField("event", "selenium_new_remote").Error(err)
-			rw.WriteHeader(500)
+			if _, ok := err.(*QueueFullError); ok {
+				rw.Header().Set("Retry-After", strconv.Itoa(configuration.Queue.RetryAfterSeconds))
+				rw.WriteHeader(503)
+			} else if _, ok := err.(*QueueTimeoutError); ok {
+				rw.Header().Set("Retry-After", strconv.Itoa(configuration.Queue.RetryAfterSeconds))
+				rw.WriteHeader(503)
+			} else if _, ok := err.(*AcquireTimeoutError); ok {
+				log.WithField("event", "session_pool_acquire").Warn(err)
+				rw.WriteHeader(503)
+			} else if _, ok := err.(*WaitTimeoutError); ok {
+				rw.WriteHeader(504)
+			} else {
+				log.WithField("event", "scrape_"+mode).Error(err)
+				rw.WriteHeader(500)
+			}
 			metricScrapeError.Inc()
 			return
 		}
 
-		defer remote.Quit()
+		scrapeRes.Timings.Total = time.Since(start).Milliseconds()
 
-		err = remote.SetPageLoadTimeout(time.Millisecond * time.Duration(configuration.Selenium.PageLoadTimeout))
+		jData, err := json.Marshal(scrapeRes)
 		if err != nil {
-			log.WithField("event", "selenium_set_timeout").Error(err)
+			log.WithField("event", "json_encode").Error(err)
 			rw.WriteHeader(500)
 			metricScrapeError.Inc()
 			return
 		}
 
-		err = remote.Get(url)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(200)
+		metricScrapeSuccess.Inc()
+		_, err = rw.Write(jData)
 		if err != nil {
-			log.WithField("event", "selenium_get").Error(err)
-			rw.WriteHeader(500)
-			metricScrapeError.Inc()
-			return
+			log.WithField("event", "write_response").Error(err)
 		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// statusScript reads the http status of the current document, only supported by recent chrome versions.
+const statusScript = `var nav = performance.getEntriesByType("navigation"); return nav.length > 0 && nav[0].responseStatus ? nav[0].responseStatus : 0;`
+
+func scrapeBrowser(scrapeReq *webscraper.ScrapeRequest) (*webscraper.GetScrapeResponse, error) {
+	scrapeRes := webscraper.GetScrapeResponse{Mode: webscraper.ModeBrowser}
 
-		element, err := remote.FindElement(selenium.ByTagName, "pre")
+	start := time.Now()
+	releaseJob, err := jobQueue.Acquire(scrapeReq.Priority)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseJob()
+
+	session, err := sessionPool.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	healthy := false
+	defer func() {
+		sessionPool.Release(session, healthy)
+	}()
+	remote := session.Remote
+	scrapeRes.Timings.Queue = time.Since(start).Milliseconds()
+
+	start = time.Now()
+	err = remote.Get(scrapeReq.Url)
+	if err != nil {
+		return nil, err
+	}
+	if scrapeReq.WaitFor != nil {
+		err = waitForSelector(remote, scrapeReq.WaitFor, time.Millisecond*time.Duration(scrapeReq.WaitTimeout))
 		if err != nil {
-			src, err := remote.PageSource()
-			if err != nil {
-				log.WithField("event", "page_get_source").Error(err)
-				rw.WriteHeader(500)
-				metricScrapeError.Inc()
-				return
-			}
-			jData, err := json.Marshal(webscraper.GetScrapeResponse{Content: src})
-			if err != nil {
-				log.WithField("event", "json_encode").Error(err)
-				rw.WriteHeader(500)
-				metricScrapeError.Inc()
-				return
-			}
+			// the session itself is still usable
+			healthy = true
+			return nil, err
+		}
+	}
+	scrapeRes.Timings.Fetch = time.Since(start).Milliseconds()
 
-			rw.Header().Set("Content-Type", "application/json")
-			rw.WriteHeader(200)
-			metricScrapeSuccess.Inc()
-			_, err = rw.Write(jData)
-			if err != nil {
-				log.WithField("event", "write_response").Error(err)
-			}
-			return
+	start = time.Now()
+	scrapeRes.FinalUrl, err = remote.CurrentURL()
+	if err != nil {
+		return nil, err
+	}
+	if status, err := remote.ExecuteScript(statusScript, nil); err == nil {
+		if statusNum, ok := status.(float64); ok {
+			scrapeRes.StatusCode = int(statusNum)
 		}
-		text, err := element.Text()
+	}
+	if contentType, err := remote.ExecuteScript("return document.contentType;", nil); err == nil {
+		scrapeRes.ContentType, _ = contentType.(string)
+	}
+
+	if len(scrapeReq.Selectors) > 0 {
+		scrapeRes.Values, err = extractSelectors(remote, scrapeReq.Selectors)
 		if err != nil {
-			log.WithField("event", "element_get_text").Error(err)
-			rw.WriteHeader(500)
-			metricScrapeError.Inc()
-			return
+			return nil, err
 		}
+		scrapeRes.Extraction = webscraper.ExtractionSelectors
+		scrapeRes.Timings.Extract = time.Since(start).Milliseconds()
+		healthy = true
+		return &scrapeRes, nil
+	}
 
-		jData, err := json.Marshal(webscraper.GetScrapeResponse{Content: text})
+	element, err := remote.FindElement(selenium.ByTagName, "pre")
+	if err != nil {
+		scrapeRes.Content, err = remote.PageSource()
 		if err != nil {
-			log.WithField("event", "json_encode").Error(err)
-			rw.WriteHeader(500)
-			metricScrapeError.Inc()
-			return
+			return nil, err
 		}
-
-		rw.Header().Set("Content-Type", "application/json")
-		rw.WriteHeader(200)
-		metricScrapeSuccess.Inc()
-		_, err = rw.Write(jData)
+		scrapeRes.Extraction = webscraper.ExtractionPageSource
+	} else {
+		scrapeRes.Content, err = element.Text()
 		if err != nil {
-			log.WithField("event", "write_response").Error(err)
+			return nil, err
 		}
+		scrapeRes.Extraction = webscraper.ExtractionPre
 	}
-	return http.HandlerFunc(fn)
+	scrapeRes.Timings.Extract = time.Since(start).Milliseconds()
+
+	healthy = true
+	return &scrapeRes, nil
 }
@@ -14,4 +14,33 @@ var (
 		Name: "webscraper_scrape_error",
 		Help: "Scrape ended with error",
 	})
+	metricBrowserFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webscraper_browser_fallbacks",
+		Help: "Auto mode scrapes that had to fall back to the browser",
+	})
+	metricQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "webscraper_queue_depth",
+		Help: "Number of scrapes waiting in the queue",
+	}, []string{"priority"})
+	metricQueueWaitTime = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "webscraper_queue_wait_seconds",
+		Help:    "Time scrapes spent waiting in the queue",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 20, 30},
+	}, []string{"priority"})
+	metricQueueRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webscraper_queue_rejected",
+		Help: "Scrapes rejected because the queue was full or the wait timed out",
+	}, []string{"priority"})
+	metricSessionsIdle = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webscraper_sessions_idle",
+		Help: "Number of idle browser sessions in the pool",
+	})
+	metricSessionsBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webscraper_sessions_busy",
+		Help: "Number of browser sessions currently in use",
+	})
+	metricSessionAcquireTimeouts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webscraper_session_acquire_timeouts",
+		Help: "Requests that timed out waiting for a browser session",
+	})
 )
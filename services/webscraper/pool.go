@@ -0,0 +1,158 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/tebeka/selenium"
+	"time"
+)
+
+type PooledSession struct {
+	Remote selenium.WebDriver
+	uses   int
+}
+
+type AcquireTimeoutError struct{}
+
+func (a AcquireTimeoutError) Error() string {
+	return "timed out waiting for a browser session"
+}
+
+// SessionPool keeps up to size warm WebDriver sessions. A slot token is held for every session that exists,
+// idle or busy, so the pool never opens more sessions than the grid is configured to accept.
+type SessionPool struct {
+	idle           chan *PooledSession
+	slots          chan struct{}
+	maxUses        int
+	acquireTimeout time.Duration
+	pageLoadTimout time.Duration
+	seleniumUrl    string
+}
+
+const defaultAcquireTimeout = time.Second * 30
+
+func NewSessionPool(config PoolConfiguration, selConfig SeleniumConfiguration) *SessionPool {
+	size := config.Size
+	if size <= 0 {
+		size = 1
+	}
+	acquireTimeout := time.Millisecond * time.Duration(config.AcquireTimeout)
+	if acquireTimeout <= 0 {
+		acquireTimeout = defaultAcquireTimeout
+	}
+	pool := &SessionPool{
+		idle:           make(chan *PooledSession, size),
+		slots:          make(chan struct{}, size),
+		maxUses:        config.MaxUses,
+		acquireTimeout: acquireTimeout,
+		pageLoadTimout: time.Millisecond * time.Duration(selConfig.PageLoadTimeout),
+		seleniumUrl:    selConfig.Url,
+	}
+	for i := 0; i < size; i++ {
+		pool.slots <- struct{}{}
+	}
+	return pool
+}
+
+func (p *SessionPool) Acquire() (*PooledSession, error) {
+	timeout := time.NewTimer(p.acquireTimeout)
+	defer timeout.Stop()
+
+	for {
+		var session *PooledSession
+		select {
+		case session = <-p.idle:
+		default:
+			select {
+			case session = <-p.idle:
+			case <-p.slots:
+				newSession, err := p.newSession()
+				if err != nil {
+					p.slots <- struct{}{}
+					return nil, err
+				}
+				metricSessionsBusy.Inc()
+				return newSession, nil
+			case <-timeout.C:
+				metricSessionAcquireTimeouts.Inc()
+				return nil, &AcquireTimeoutError{}
+			}
+		}
+
+		metricSessionsIdle.Dec()
+		if p.isHealthy(session) {
+			metricSessionsBusy.Inc()
+			return session, nil
+		}
+		log.WithField("event", "session_pool_unhealthy").Info("Discarding unhealthy browser session")
+		p.discard(session)
+	}
+}
+
+// Release hands a session back to the pool. Sessions that errored or reached their max uses are closed.
+func (p *SessionPool) Release(session *PooledSession, healthy bool) {
+	metricSessionsBusy.Dec()
+	session.uses++
+
+	if !healthy || (p.maxUses > 0 && session.uses >= p.maxUses) {
+		p.discard(session)
+		return
+	}
+	metricSessionsIdle.Inc()
+	p.idle <- session
+}
+
+// KeepAlive periodically pings idle sessions so the grid does not expire them and broken sessions get replaced.
+func (p *SessionPool) KeepAlive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	for range time.Tick(interval) {
+		count := len(p.idle)
+		for i := 0; i < count; i++ {
+			var session *PooledSession
+			select {
+			case session = <-p.idle:
+			default:
+			}
+			if session == nil {
+				break
+			}
+
+			if p.isHealthy(session) {
+				p.idle <- session
+				continue
+			}
+			metricSessionsIdle.Dec()
+			log.WithField("event", "session_pool_unhealthy").Info("Discarding unhealthy browser session")
+			p.discard(session)
+		}
+	}
+}
+
+func (p *SessionPool) newSession() (*PooledSession, error) {
+	remote, err := selenium.NewRemote(selCaps, p.seleniumUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	err = remote.SetPageLoadTimeout(p.pageLoadTimout)
+	if err != nil {
+		_ = remote.Quit()
+		return nil, err
+	}
+
+	return &PooledSession{Remote: remote}, nil
+}
+
+func (p *SessionPool) isHealthy(session *PooledSession) bool {
+	_, err := session.Remote.CurrentURL()
+	return err == nil
+}
+
+func (p *SessionPool) discard(session *PooledSession) {
+	err := session.Remote.Quit()
+	if err != nil {
+		log.WithField("event", "selenium_quit").Warn(err)
+	}
+	p.slots <- struct{}{}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueMaxDepth = 50
+	defaultQueueTimeout  = time.Second * 20
+)
+
+var priorityOrder = []webscraper.Priority{webscraper.PriorityInteractive, webscraper.PriorityDefault, webscraper.PriorityBackground}
+
+type QueueFullError struct{}
+
+func (q QueueFullError) Error() string {
+	return "scrape queue is full"
+}
+
+type QueueTimeoutError struct{}
+
+func (q QueueTimeoutError) Error() string {
+	return "timed out waiting in scrape queue"
+}
+
+type queuedJob struct {
+	ready    chan struct{}
+	priority webscraper.Priority
+}
+
+// JobQueue limits the number of concurrently running scrapes. Waiting jobs are started
+// in priority order, FIFO within the same priority.
+type JobQueue struct {
+	mu          sync.Mutex
+	running     int
+	concurrency int
+	maxDepth    int
+	timeout     time.Duration
+	waiting     map[webscraper.Priority][]*queuedJob
+}
+
+func NewJobQueue(config QueueConfiguration) *JobQueue {
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	maxDepth := config.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultQueueMaxDepth
+	}
+	timeout := time.Millisecond * time.Duration(config.Timeout)
+	if timeout <= 0 {
+		timeout = defaultQueueTimeout
+	}
+	return &JobQueue{
+		concurrency: concurrency,
+		maxDepth:    maxDepth,
+		timeout:     timeout,
+		waiting:     make(map[webscraper.Priority][]*queuedJob),
+	}
+}
+
+func ParsePriority(priority string) webscraper.Priority {
+	for _, p := range priorityOrder {
+		if string(p) == priority {
+			return p
+		}
+	}
+	return webscraper.PriorityDefault
+}
+
+// Acquire blocks until the job may run. The returned release function has to be called once the job is done.
+func (q *JobQueue) Acquire(priority webscraper.Priority) (func(), error) {
+	start := time.Now()
+
+	q.mu.Lock()
+	if q.running < q.concurrency && q.depth() == 0 {
+		q.running++
+		q.mu.Unlock()
+		metricQueueWaitTime.WithLabelValues(string(priority)).Observe(0)
+		return q.release, nil
+	}
+	if q.depth() >= q.maxDepth {
+		q.mu.Unlock()
+		metricQueueRejected.WithLabelValues(string(priority)).Inc()
+		return nil, &QueueFullError{}
+	}
+	job := &queuedJob{ready: make(chan struct{}), priority: priority}
+	q.waiting[priority] = append(q.waiting[priority], job)
+	metricQueueDepth.WithLabelValues(string(priority)).Inc()
+	q.mu.Unlock()
+
+	timeout := time.NewTimer(q.timeout)
+	defer timeout.Stop()
+
+	select {
+	case <-job.ready:
+		metricQueueWaitTime.WithLabelValues(string(priority)).Observe(time.Since(start).Seconds())
+		return q.release, nil
+	case <-timeout.C:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.remove(job) {
+			metricQueueRejected.WithLabelValues(string(priority)).Inc()
+			return nil, &QueueTimeoutError{}
+		}
+		// the job was started while the timer fired
+		metricQueueWaitTime.WithLabelValues(string(priority)).Observe(time.Since(start).Seconds())
+		return q.release, nil
+	}
+}
+
+// release hands the slot directly to the next waiting job or frees it.
+func (q *JobQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, priority := range priorityOrder {
+		jobs := q.waiting[priority]
+		if len(jobs) > 0 {
+			q.waiting[priority] = jobs[1:]
+			metricQueueDepth.WithLabelValues(string(priority)).Dec()
+			close(jobs[0].ready)
+			return
+		}
+	}
+	q.running--
+}
+
+func (q *JobQueue) remove(job *queuedJob) bool {
+	jobs := q.waiting[job.priority]
+	for i, j := range jobs {
+		if j == job {
+			q.waiting[job.priority] = append(jobs[:i:i], jobs[i+1:]...)
+			metricQueueDepth.WithLabelValues(string(job.priority)).Dec()
+			return true
+		}
+	}
+	return false
+}
+
+func (q *JobQueue) depth() int {
+	depth := 0
+	for _, jobs := range q.waiting {
+		depth += len(jobs)
+	}
+	return depth
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/tebeka/selenium"
+	"github.com/yannismate/yannismate-api/libs/rest/webscraper"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxSelectors = 50
+const maxWaitTimeout = time.Second * 30
+
+type WaitTimeoutError struct {
+	Selector string
+}
+
+func (w WaitTimeoutError) Error() string {
+	return "timed out waiting for selector " + w.Selector
+}
+
+// parseScrapeRequest reads a scrape request either from a posted JSON body or from the query parameters
+// url, mode, selector (repeatable css selector), waitFor (css selector) and waitTimeout.
+func parseScrapeRequest(r *http.Request) (*webscraper.ScrapeRequest, error) {
+	scrapeReq := webscraper.ScrapeRequest{}
+
+	if r.Method == http.MethodPost {
+		err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&scrapeReq)
+		if err != nil {
+			return nil, errors.New("invalid request body")
+		}
+	} else {
+		query := r.URL.Query()
+		scrapeReq.Url = query.Get("url")
+		scrapeReq.Mode = webscraper.Mode(query.Get("mode"))
+		scrapeReq.Priority = webscraper.Priority(query.Get("priority"))
+		for _, selector := range query["selector"] {
+			scrapeReq.Selectors = append(scrapeReq.Selectors, webscraper.Selector{Type: webscraper.SelectorCss, Query: selector})
+		}
+		if waitFor := query.Get("waitFor"); waitFor != "" {
+			scrapeReq.WaitFor = &webscraper.Selector{Type: webscraper.SelectorCss, Query: waitFor}
+		}
+		if waitTimeout := query.Get("waitTimeout"); waitTimeout != "" {
+			timeout, err := strconv.Atoi(waitTimeout)
+			if err != nil {
+				return nil, errors.New("waitTimeout has to be a number")
+			}
+			scrapeReq.WaitTimeout = timeout
+		}
+	}
+
+	if scrapeReq.Url == "" {
+		return nil, errors.New("no url specified")
+	}
+
+	scrapeReq.Priority = ParsePriority(strings.ToLower(string(scrapeReq.Priority)))
+	scrapeReq.Mode = webscraper.Mode(strings.ToLower(string(scrapeReq.Mode)))
+	if scrapeReq.Mode == "" {
+		scrapeReq.Mode = configuration.DefaultMode
+	}
+	if scrapeReq.Mode == "" {
+		scrapeReq.Mode = webscraper.ModeBrowser
+	}
+
+	switch scrapeReq.Mode {
+	case webscraper.ModeHttp, webscraper.ModeBrowser, webscraper.ModeAuto:
+	default:
+		return nil, errors.New("invalid mode")
+	}
+
+	if len(scrapeReq.Selectors) > 0 || scrapeReq.WaitFor != nil {
+		// selectors need a rendered page
+		if scrapeReq.Mode == webscraper.ModeHttp {
+			return nil, errors.New("selectors are not supported in http mode")
+		}
+		scrapeReq.Mode = webscraper.ModeBrowser
+	}
+
+	if len(scrapeReq.Selectors) > maxSelectors {
+		return nil, errors.New("too many selectors")
+	}
+	keys := make(map[string]bool, len(scrapeReq.Selectors))
+	for i := range scrapeReq.Selectors {
+		err := validateSelector(&scrapeReq.Selectors[i])
+		if err != nil {
+			return nil, err
+		}
+		key := scrapeReq.Selectors[i].Key()
+		if keys[key] {
+			return nil, errors.New("duplicate selector key " + key)
+		}
+		keys[key] = true
+	}
+	if scrapeReq.WaitFor != nil {
+		err := validateSelector(scrapeReq.WaitFor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &scrapeReq, nil
+}
+
+func validateSelector(selector *webscraper.Selector) error {
+	if selector.Query == "" {
+		return errors.New("empty selector query")
+	}
+	if selector.Type == "" {
+		selector.Type = webscraper.SelectorCss
+	}
+	if selector.Type != webscraper.SelectorCss && selector.Type != webscraper.SelectorXPath {
+		return errors.New("invalid selector type " + string(selector.Type))
+	}
+	return nil
+}
+
+func selectorBy(selector *webscraper.Selector) string {
+	if selector.Type == webscraper.SelectorXPath {
+		return selenium.ByXPATH
+	}
+	return selenium.ByCSSSelector
+}
+
+func waitForSelector(remote selenium.WebDriver, selector *webscraper.Selector, timeout time.Duration) error {
+	if timeout <= 0 || timeout > maxWaitTimeout {
+		timeout = maxWaitTimeout
+	}
+
+	var findErr error
+	err := remote.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+		elements, err := wd.FindElements(selectorBy(selector), selector.Query)
+		if err != nil {
+			if isNoSuchElement(err) {
+				return false, nil
+			}
+			findErr = err
+			return false, err
+		}
+		return len(elements) > 0, nil
+	}, timeout)
+	if findErr != nil {
+		return findErr
+	}
+	if err != nil {
+		return &WaitTimeoutError{Selector: selector.Query}
+	}
+	return nil
+}
+
+// isNoSuchElement reports whether err is the driver telling us that nothing matched, which some drivers
+// return from FindElements instead of an empty list.
+func isNoSuchElement(err error) bool {
+	var selErr *selenium.Error
+	if !errors.As(err, &selErr) {
+		return false
+	}
+	// 7 is the NoSuchElement code of the legacy JSON wire protocol
+	return selErr.Err == "no such element" || selErr.LegacyCode == 7
+}
+
+// extractSelectors returns the text or requested attribute of all elements matching each selector.
+func extractSelectors(remote selenium.WebDriver, selectors []webscraper.Selector) (map[string][]string, error) {
+	values := make(map[string][]string, len(selectors))
+
+	for _, selector := range selectors {
+		elements, err := remote.FindElements(selectorBy(&selector), selector.Query)
+		if err != nil {
+			if !isNoSuchElement(err) {
+				return nil, err
+			}
+			values[selector.Key()] = []string{}
+			continue
+		}
+
+		selectorValues := make([]string, 0, len(elements))
+		for _, element := range elements {
+			var value string
+			if selector.Attribute != "" {
+				value, err = element.GetAttribute(selector.Attribute)
+			} else {
+				value, err = element.Text()
+			}
+			if err != nil {
+				return nil, err
+			}
+			selectorValues = append(selectorValues, value)
+		}
+		values[selector.Key()] = selectorValues
+	}
+
+	return values, nil
+}